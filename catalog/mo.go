@@ -0,0 +1,123 @@
+package catalog
+
+import (
+	gt "github.com/Sam-Izdat/pogo/deps/gettext"
+	spec "github.com/Sam-Izdat/pogo/gtspec"
+	"io"
+	"sort"
+)
+
+// MoStats tallies the entries of a catalog passed through CompileMo
+type MoStats struct {
+	Translated, Fuzzy, Untranslated int
+}
+
+// CompileMo writes msgs to w as a binary MO catalog. Untranslated messages
+// are always left out and fuzzy ones are only included if fuzzy is true.
+// The header (empty msgid) is written regardless.
+func CompileMo(w io.WriteSeeker, msgs []spec.Msg, fuzzy bool) (MoStats, error) {
+	iter, stats := NewIterator(msgs, fuzzy)
+	return stats, gt.WriteMo(w, iter)
+}
+
+// NewIterator returns an iterator over the messages CompileMo would write,
+// converted for the gettext package, e.g. to fill a catalog with.
+func NewIterator(msgs []spec.Msg, fuzzy bool) (gt.Iterator, MoStats) {
+	var (
+		res   []*gt.Message
+		stats MoStats
+	)
+	for _, v := range msgs {
+		if v.Obsolete {
+			continue
+		}
+		if v.Id == "" && v.Ctxt == "" {
+			res = append(res, moMessage(v))
+			continue
+		}
+		switch {
+		case !isTranslated(v):
+			stats.Untranslated++
+			continue
+		case HasFlag(v, "fuzzy"):
+			stats.Fuzzy++
+			if !fuzzy {
+				continue
+			}
+		default:
+			stats.Translated++
+		}
+		res = append(res, moMessage(v))
+	}
+
+	// GNU gettext expects the original strings to be sorted
+	sort.Sort(moMessages(res))
+	return &moIterator{msgs: res}, stats
+}
+
+// isTranslated reports whether every msgstr of a message is filled in
+func isTranslated(msg spec.Msg) bool {
+	if msg.IdPlural == "" {
+		return msg.Str != ""
+	}
+	if len(msg.StrPlural) == 0 {
+		return false
+	}
+	for _, v := range msg.StrPlural {
+		if v == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// moMessage converts an escaped PO message into its raw MO equivalent
+func moMessage(msg spec.Msg) *gt.Message {
+	res := &gt.Message{Id: []byte(Unescape(msg.Id))}
+	if msg.Ctxt != "" {
+		res.Ctxt = []byte(Unescape(msg.Ctxt))
+	}
+	if msg.IdPlural == "" {
+		res.Str = []byte(Unescape(msg.Str))
+		return res
+	}
+	res.IdPlural = []byte(Unescape(msg.IdPlural))
+	for _, v := range msg.StrPlural {
+		res.StrPlural = append(res.StrPlural, []byte(Unescape(v)))
+	}
+	return res
+}
+
+// moMessages sorts messages the way they are keyed in a MO file
+type moMessages []*gt.Message
+
+func (m moMessages) Len() int      { return len(m) }
+func (m moMessages) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m moMessages) Less(i, j int) bool {
+	return moKey(m[i]) < moKey(m[j])
+}
+
+func moKey(msg *gt.Message) string {
+	if msg.Ctxt == nil {
+		return string(msg.Id)
+	}
+	return string(msg.Ctxt) + "\x04" + string(msg.Id)
+}
+
+// moIterator feeds a slice of messages to gettext.WriteMo
+type moIterator struct {
+	msgs []*gt.Message
+	pos  int
+}
+
+func (i *moIterator) Size() int {
+	return len(i.msgs)
+}
+
+func (i *moIterator) Next() (*gt.Message, error) {
+	if i.pos >= len(i.msgs) {
+		return nil, io.EOF
+	}
+	i.pos++
+	return i.msgs[i.pos-1], nil
+}
@@ -0,0 +1,338 @@
+// Package catalog reads PO catalogs and compiles them to MO. Unlike the
+// po package, which scans source and builds catalogs from it, it has no
+// side effects, so that the translate package can read catalogs at run
+// time without loading a configuration or pulling in the scanners.
+package catalog
+
+import (
+	"bufio"
+	"fmt"
+	spec "github.com/Sam-Izdat/pogo/gtspec"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError reports a syntax error in a PO catalog
+type ParseError struct {
+	File string // name of the catalog, if known
+	Line int    // line number within the catalog
+	Err  string
+}
+
+func (e *ParseError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+}
+
+// Reader reads the messages of a PO catalog one at a time. Strings are left
+// in their escaped PO form, just as the scanners produce them, so that
+// parsed messages can be handed straight back to Compile.
+type Reader struct {
+	fn      string
+	scanner *bufio.Scanner
+	ln      int    // current line number
+	pending string // line read ahead that opens the next message
+	held    bool   // pending is set
+	err     error  // sticky error
+}
+
+// NewReader returns a Reader for the catalog r; fn is used in error
+// reports and may be empty.
+func NewReader(r io.Reader, fn string) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &Reader{fn: fn, scanner: scanner}
+}
+
+// ParseFile reads and parses the PO catalog at path fn.
+func ParseFile(fn string) ([]spec.Msg, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseAll(NewReader(f, fn))
+}
+
+// Parse reads a PO catalog from r and returns all of its messages. The
+// header comes first, as the message with an empty msgid, and obsolete
+// ("#~") messages are returned with the Obsolete field set.
+func Parse(r io.Reader) ([]spec.Msg, error) {
+	return parseAll(NewReader(r, ""))
+}
+
+func parseAll(r *Reader) (res []spec.Msg, err error) {
+	for {
+		msg, err := r.Next()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, msg)
+	}
+}
+
+// Next returns the next message of the catalog, or io.EOF when there are
+// none left. Any other error is a *ParseError or comes from the underlying
+// reader, and is returned again by every subsequent call.
+func (r *Reader) Next() (spec.Msg, error) {
+	if r.err != nil {
+		return spec.Msg{}, r.err
+	}
+	var (
+		msg     = spec.Msg{Comments: make(spec.CommentPack)}
+		started bool   // msgid has been read
+		done    bool   // msgstr has been read
+		last    string // keyword that continuation lines belong to
+		empty   = true // nothing at all has been read
+	)
+	for {
+		line, ok := r.readLine()
+		if !ok {
+			break
+		}
+		if line == "" {
+			continue
+		}
+
+		// obsolete entries are regular entries commented out with "#~"
+		obsolete := strings.HasPrefix(line, "#~")
+		if obsolete {
+			line = strings.TrimSpace(line[2:])
+			if line == "" { // a bare "#~" is as good as a blank line
+				continue
+			}
+			if strings.HasPrefix(line, "|") {
+				line = "#" + line
+			}
+		}
+
+		if done && (line[0] == '#' || strings.HasPrefix(line, "msgctxt") ||
+			(strings.HasPrefix(line, "msgid") && !strings.HasPrefix(line, "msgid_plural"))) {
+			if obsolete {
+				line = "#~ " + line
+			}
+			r.pending, r.held = line, true
+			return msg, nil
+		}
+		if obsolete {
+			msg.Obsolete = true
+		}
+		empty = false
+
+		if line[0] == '#' {
+			if started {
+				return r.fail("comment inside a message")
+			}
+			key, text := "translator", line[1:]
+			if len(text) > 0 {
+				switch text[0] {
+				case ':':
+					key, text = "reference", text[1:]
+				case '.':
+					key, text = "extracted", text[1:]
+				case ',':
+					key, text = "flag", text[1:]
+				case '|':
+					key, text = "previous", text[1:]
+				}
+			}
+			if key == "translator" {
+				text = strings.TrimPrefix(text, " ")
+			} else {
+				text = strings.TrimSpace(text)
+			}
+			msg.Comments[key] = append(msg.Comments[key], text)
+			continue
+		}
+
+		if line[0] == '"' {
+			s, ok := unquotePOString(line)
+			if !ok {
+				return r.fail("malformed string %s", line)
+			}
+			switch last {
+			case "msgctxt":
+				msg.Ctxt += s
+			case "msgid":
+				msg.Id += s
+			case "msgid_plural":
+				msg.IdPlural += s
+			case "msgstr":
+				msg.Str += s
+			case "msgstr[]":
+				msg.StrPlural[len(msg.StrPlural)-1] += s
+			default:
+				return r.fail("string outside of any keyword")
+			}
+			continue
+		}
+
+		kw, val := line, ""
+		if i := strings.IndexAny(line, " \t"); i > 0 {
+			kw, val = line[:i], strings.TrimSpace(line[i:])
+		}
+		s, ok := unquotePOString(val)
+		switch {
+		case kw == "msgctxt":
+			if started || last == "msgctxt" {
+				return r.fail("unexpected msgctxt")
+			}
+			msg.Ctxt = s
+		case kw == "msgid":
+			if started {
+				return r.fail("missing msgstr before msgid")
+			}
+			msg.Id, started = s, true
+		case kw == "msgid_plural":
+			if last != "msgid" {
+				return r.fail("unexpected msgid_plural")
+			}
+			msg.IdPlural = s
+		case kw == "msgstr":
+			if !started || done || msg.IdPlural != "" {
+				return r.fail("unexpected msgstr")
+			}
+			msg.Str, done = s, true
+		case strings.HasPrefix(kw, "msgstr[") && strings.HasSuffix(kw, "]"):
+			n, err := strconv.Atoi(kw[7 : len(kw)-1])
+			if err != nil || msg.IdPlural == "" || n != len(msg.StrPlural) {
+				return r.fail("unexpected %s", kw)
+			}
+			msg.StrPlural, done = append(msg.StrPlural, s), true
+			kw = "msgstr[]"
+		default:
+			return r.fail("unknown keyword %q", kw)
+		}
+		if !ok {
+			return r.fail("malformed string after %s", kw)
+		}
+		last = kw
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		r.err = err
+		return spec.Msg{}, err
+	}
+	switch {
+	case empty:
+		r.err = io.EOF
+		return spec.Msg{}, io.EOF
+	case !started:
+		return r.fail("comments without a message")
+	case !done:
+		return r.fail("missing msgstr")
+	}
+	return msg, nil
+}
+
+// readLine returns the next trimmed line, starting with one held back
+func (r *Reader) readLine() (string, bool) {
+	if r.held {
+		r.held = false
+		return r.pending, true
+	}
+	if !r.scanner.Scan() {
+		return "", false
+	}
+	r.ln++
+	return strings.TrimSpace(r.scanner.Text()), true
+}
+
+func (r *Reader) fail(format string, a ...interface{}) (spec.Msg, error) {
+	r.err = &ParseError{r.fn, r.ln, fmt.Sprintf(format, a...)}
+	return spec.Msg{}, r.err
+}
+
+// unquotePOString strips the surrounding double quotes from a PO string,
+// leaving escape sequences untouched.
+func unquotePOString(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", false
+	}
+	s = s[1 : len(s)-1]
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i == len(s)-1 {
+				return "", false
+			}
+			i++
+		case '"':
+			return "", false
+		}
+	}
+	return s, true
+}
+
+// HasFlag reports whether a message carries the given "#," flag.
+func HasFlag(msg spec.Msg, flag string) bool {
+	for _, line := range msg.Comments["flag"] {
+		for _, f := range strings.Split(line, ",") {
+			if strings.TrimSpace(f) == flag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Unescape resolves the C-style escape sequences of a PO string
+func Unescape(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			buf = append(buf, s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'n':
+			buf = append(buf, '\n')
+		case 't':
+			buf = append(buf, '\t')
+		case 'r':
+			buf = append(buf, '\r')
+		case 'a':
+			buf = append(buf, '\a')
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'v':
+			buf = append(buf, '\v')
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			n, j := 0, i
+			for ; j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7'; j++ {
+				n = n*8 + int(s[j]-'0')
+			}
+			buf, i = append(buf, byte(n)), j-1
+		case 'x', 'u', 'U':
+			end := min(i+1+map[byte]int{'x': 2, 'u': 4, 'U': 8}[c], len(s))
+			n, err := strconv.ParseUint(s[i+1:end], 16, 32)
+			if err != nil {
+				buf = append(buf, '\\', c)
+				continue
+			}
+			if c == 'x' {
+				buf = append(buf, byte(n))
+			} else {
+				buf = utf8.AppendRune(buf, rune(n))
+			}
+			i = end - 1
+		default: // \\, \", \' and anything unknown
+			buf = append(buf, c)
+		}
+	}
+	return string(buf)
+}
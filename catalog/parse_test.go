@@ -0,0 +1,146 @@
+package catalog
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	spec "github.com/Sam-Izdat/pogo/gtspec"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []spec.Msg
+	}{
+		{"plain", `
+# a note
+#. extracted
+#: a.go:1 b.go:2
+#, fuzzy
+msgid "Hello"
+msgstr "Hallo"
+`, []spec.Msg{{Id: "Hello", Str: "Hallo", Comments: spec.CommentPack{
+			"translator": {"a note"}, "extracted": {"extracted"},
+			"reference": {"a.go:1 b.go:2"}, "flag": {"fuzzy"}}}}},
+		{"plural", `
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d Datei"
+msgstr[1] "%d Dateien"
+`, []spec.Msg{{Id: "%d file", IdPlural: "%d files", StrPlural: []string{"%d Datei", "%d Dateien"}}}},
+		{"context", `
+msgctxt "menu"
+msgid "Open"
+msgstr "Öffnen"
+
+msgid "Open"
+msgstr "Offen"
+`, []spec.Msg{{Ctxt: "menu", Id: "Open", Str: "Öffnen"}, {Id: "Open", Str: "Offen"}}},
+		{"multi-line", `
+msgid ""
+msgstr ""
+"Language: de\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+msgid ""
+"a long "
+"string \"quoted\""
+msgstr "x"
+`, []spec.Msg{
+			{Str: `Language: de\nPlural-Forms: nplurals=2; plural=(n != 1);\n`},
+			{Id: `a long string \"quoted\"`, Str: "x"}}},
+		{"obsolete", `
+msgid "kept"
+msgstr "k"
+
+#~ msgctxt "old"
+#~ msgid "gone"
+#~ msgstr "g"
+#~
+#~ msgid "also gone"
+#~ msgstr "a"
+`, []spec.Msg{{Id: "kept", Str: "k"},
+			{Ctxt: "old", Id: "gone", Str: "g", Obsolete: true},
+			{Id: "also gone", Str: "a", Obsolete: true}}},
+		{"previous", `
+#, fuzzy
+#| msgctxt "old"
+#| msgid "Helo"
+msgid "Hello"
+msgstr "Hallo"
+
+#~ #| msgid "Bye"
+#~ msgid "Goodbye"
+#~ msgstr "Tschüss"
+`, []spec.Msg{
+			{Id: "Hello", Str: "Hallo", Comments: spec.CommentPack{
+				"flag": {"fuzzy"}, "previous": {`msgctxt "old"`, `msgid "Helo"`}}},
+			{Id: "Goodbye", Str: "Tschüss", Obsolete: true, Comments: spec.CommentPack{
+				"previous": {`msgid "Bye"`}}}}},
+		{"stray obsolete marker", "#~\nmsgid \"a\"\nmsgstr \"b\"\n#~\n",
+			[]spec.Msg{{Id: "a", Str: "b"}}},
+		{"empty", "\n\n", nil},
+	}
+	for _, test := range tests {
+		msgs, err := Parse(strings.NewReader(test.src))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		for k := range msgs {
+			if len(msgs[k].Comments) == 0 {
+				msgs[k].Comments = nil
+			}
+		}
+		if !reflect.DeepEqual(msgs, test.want) {
+			t.Errorf("%s: expected\n%#v\ngot\n%#v", test.name, test.want, msgs)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, src string
+		line      int
+	}{
+		{"missing msgstr", "msgid \"a\"\n", 1},
+		{"msgstr without msgid", "msgstr \"a\"\n", 1},
+		{"unknown keyword", "msgid \"a\"\nmsgstring \"b\"\n", 2},
+		{"unterminated string", "msgid \"a\nmsgstr \"b\"\n", 1},
+		{"stray quote", "msgid \"a\"b\"\nmsgstr \"\"\n", 1},
+		{"string outside keyword", "\"a\"\n", 1},
+		{"comment inside message", "msgid \"a\"\n# note\nmsgstr \"b\"\n", 2},
+		{"plural out of order", "msgid \"a\"\nmsgid_plural \"b\"\nmsgstr[1] \"c\"\n", 3},
+		{"msgstr for plural", "msgid \"a\"\nmsgid_plural \"b\"\nmsgstr \"c\"\n", 3},
+		{"comments only", "# note\n", 1},
+		{"two msgids", "msgid \"a\"\nmsgid \"b\"\nmsgstr \"\"\n", 2},
+	}
+	for _, test := range tests {
+		_, err := Parse(strings.NewReader(test.src))
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%s: expected a *ParseError, got %v", test.name, err)
+			continue
+		}
+		if perr.Line != test.line {
+			t.Errorf("%s: expected an error on line %d, got %v", test.name, test.line, perr)
+		}
+	}
+}
+
+func TestReaderStickyError(t *testing.T) {
+	r := NewReader(strings.NewReader("msgid \"a\"\nmsgstr \"b\"\n\nmsgid \"c\"\nbogus\n"), "x.po")
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := r.Next()
+	if err == nil || err == io.EOF || !strings.HasPrefix(err.Error(), "x.po:5:") {
+		t.Fatalf("expected an error on x.po:5, got %v", err)
+	}
+	if _, again := r.Next(); again != err {
+		t.Errorf("expected the same error again, got %v", again)
+	}
+}
@@ -46,7 +46,11 @@ func (c *Catalog) Singular(key string, args ...interface{}) string {
 
 // ReadMo reads a MO file from r and adds its messages to the catalog.
 func (c *Catalog) ReadMo(r io.ReadSeeker) error {
-	iter := ReadMo(r)
+	return c.Read(ReadMo(r))
+}
+
+// Read adds the messages provided by iter to the catalog.
+func (c *Catalog) Read(iter Iterator) error {
 	size := iter.Size()
 	for i := 0; i < size; i++ {
 		msg, err := iter.Next()
@@ -56,6 +60,14 @@ func (c *Catalog) ReadMo(r io.ReadSeeker) error {
 		// TODO: check this error
 		c.setMessage(msg)
 	}
+	// The iterator should be exhausted now; anything other than io.EOF
+	// means it failed early, e.g. on a MO file with an unreadable header.
+	if _, err := iter.Next(); err != io.EOF {
+		if err == nil {
+			return fmt.Errorf("Iterator has more messages than reported.")
+		}
+		return err
+	}
 	return nil
 }
 
@@ -11,7 +11,7 @@ import (
 var POGO = translate.LoadCfg("github.com/Sam-Izdat/pogo/example")
 
 func handler(w http.ResponseWriter, r *http.Request) {
-	var T = POGO.New("ru")
+	T, _ := translate.FromContext(r.Context())
 
 	var bottles []int
 	for i := 99; i >= 0; i-- {
@@ -37,6 +37,7 @@ func handler(w http.ResponseWriter, r *http.Request) {
 func main() {
 	port := ":8383"
 	fmt.Println("Serving on port", port)
-	http.HandleFunc("/", handler)
+	http.Handle("/", POGO.Middleware(http.HandlerFunc(handler),
+		translate.ByQuery("lang"), translate.ByAcceptLanguage()))
 	http.ListenAndServe(port, nil)
 }
@@ -0,0 +1,503 @@
+package gtspec
+
+//go:generate go run gen_cldr.go -version 44.1
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PluralCategory is a CLDR plural category
+type PluralCategory string
+
+const (
+	PluralZero  PluralCategory = "zero"
+	PluralOne   PluralCategory = "one"
+	PluralTwo   PluralCategory = "two"
+	PluralFew   PluralCategory = "few"
+	PluralMany  PluralCategory = "many"
+	PluralOther PluralCategory = "other"
+)
+
+// Operands are the CLDR plural operands of a number, which unlike a plain
+// int tell 1 from 1.0 and 1.5 (see Unicode TR35, "Plural Operand Meanings")
+type Operands struct {
+	N float64 // absolute value
+	I int64   // integer digits
+	V int64   // number of visible fraction digits, with trailing zeros
+	W int64   // number of visible fraction digits, without trailing zeros
+	F int64   // visible fraction digits, with trailing zeros
+	T int64   // visible fraction digits, without trailing zeros
+	E int64   // exponent of compact decimal notation, e.g. 3 in "1.2c3"
+}
+
+// IsInt reports whether the number has no visible fraction digits
+func (op Operands) IsInt() bool { return op.V == 0 }
+
+// ParseOperands takes the operands from a formatted decimal number such as
+// "3", "-1.50" or "1.2c6", the last being 1200000 in compact notation
+func ParseOperands(s string) (op Operands, err error) {
+	num := strings.TrimPrefix(strings.TrimSpace(s), "-")
+	if i := strings.IndexAny(num, "ce"); i >= 0 {
+		if op.E, err = strconv.ParseInt(num[i+1:], 10, 64); err != nil || op.E < 0 || op.E > 18 {
+			return Operands{}, fmt.Errorf("number %q: bad exponent", s)
+		}
+		num = num[:i]
+	}
+	ip, fp, _ := strings.Cut(num, ".")
+	if ip == "" || strings.Trim(ip+fp, "0123456789") != "" {
+		return Operands{}, fmt.Errorf("number %q: not a decimal number", s)
+	}
+	for e := op.E; e > 0; e-- { // shift the point right
+		if fp == "" {
+			ip += "0"
+		} else {
+			ip, fp = ip+fp[:1], fp[1:]
+		}
+	}
+	if op.I, err = strconv.ParseInt(ip, 10, 64); err != nil {
+		return Operands{}, fmt.Errorf("number %q: %v", s, err)
+	}
+	if len(fp) > 18 {
+		fp = fp[:18]
+	}
+	op.V = int64(len(fp))
+	tp := strings.TrimRight(fp, "0")
+	op.W = int64(len(tp))
+	op.F, _ = strconv.ParseInt("0"+fp, 10, 64)
+	op.T, _ = strconv.ParseInt("0"+tp, 10, 64)
+	op.N, _ = strconv.ParseFloat(ip+"."+fp+"0", 64)
+	return op, nil
+}
+
+// OperandsOf takes the operands from an integer, a float (in its shortest
+// representation, so 2.0 counts as 2) or a formatted decimal string.
+func OperandsOf(x interface{}) (Operands, error) {
+	switch x := x.(type) {
+	case int:
+		return intOperands(int64(x)), nil
+	case int8:
+		return intOperands(int64(x)), nil
+	case int16:
+		return intOperands(int64(x)), nil
+	case int32:
+		return intOperands(int64(x)), nil
+	case int64:
+		return intOperands(x), nil
+	case uint:
+		return ParseOperands(strconv.FormatUint(uint64(x), 10))
+	case uint8:
+		return intOperands(int64(x)), nil
+	case uint16:
+		return intOperands(int64(x)), nil
+	case uint32:
+		return intOperands(int64(x)), nil
+	case uint64:
+		return ParseOperands(strconv.FormatUint(x, 10))
+	case float32:
+		return ParseOperands(strconv.FormatFloat(float64(x), 'f', -1, 32))
+	case float64:
+		if math.IsInf(x, 0) || math.IsNaN(x) {
+			return Operands{}, fmt.Errorf("number %v: not finite", x)
+		}
+		return ParseOperands(strconv.FormatFloat(x, 'f', -1, 64))
+	case string:
+		return ParseOperands(x)
+	}
+	return Operands{}, fmt.Errorf("%T is not a number", x)
+}
+
+func intOperands(n int64) Operands {
+	if n < 0 {
+		n = -n
+	}
+	return Operands{N: float64(n), I: n}
+}
+
+// GetPluralCategory returns the CLDR cardinal plural category of a number
+// in a locale, or in its base language if the locale has no rules of its
+// own. It reports false if neither has rules.
+func GetPluralCategory(locale string, op Operands) (PluralCategory, bool) {
+	rules, ok := cldrRuleSet(cardinalRules, locale)
+	if !ok {
+		return PluralOther, false
+	}
+	return rules.category(op), true
+}
+
+// GetOrdinalCategory returns the CLDR ordinal plural category of a number
+// in a locale, e.g. "two" for 22 in English ("22nd"), or in its base
+// language if the locale has no rules of its own. It reports false if
+// neither has rules.
+func GetOrdinalCategory(locale string, op Operands) (PluralCategory, bool) {
+	rules, ok := cldrRuleSet(ordinalRules, locale)
+	if !ok {
+		return PluralOther, false
+	}
+	return rules.category(op), true
+}
+
+// OrdinalPrefix starts the msgctxt of the forms of ordinal messages, which
+// catalogs hold as a message per ordinal category, so that they keep to the
+// catalog's nplurals and don't collide with plain messages of the same
+// text. Contexts starting with it are reserved.
+const OrdinalPrefix = "ordinal:"
+
+// OrdinalContext returns the msgctxt of the form an ordinal message with
+// context ctxt, if any, takes for a category, e.g. "ordinal:two" or
+// "ordinal:two|race"
+func OrdinalContext(cat PluralCategory, ctxt string) string {
+	if ctxt == "" {
+		return OrdinalPrefix + string(cat)
+	}
+	return OrdinalPrefix + string(cat) + "|" + ctxt
+}
+
+// GetOrdinalForms lists the ordinal categories of a locale in CLDR order
+// (zero, one, two, few, many, other). Catalogs hold a form of an ordinal
+// message for each (see OrdinalContext). A locale without rules has only
+// "other".
+func GetOrdinalForms(locale string) []PluralCategory {
+	rules, ok := cldrRuleSet(ordinalRules, locale)
+	if !ok {
+		return []PluralCategory{PluralOther}
+	}
+	return rules.forms()
+}
+
+// GetOrdinalIdx returns the index in GetOrdinalForms of the ordinal form
+// a number takes in a locale
+func GetOrdinalIdx(locale string, op Operands) int {
+	cat, _ := GetOrdinalCategory(locale, op)
+	for k, v := range GetOrdinalForms(locale) {
+		if v == cat {
+			return k
+		}
+	}
+	return 0
+}
+
+// GetPluralIdxOperands is like GetPluralIdxForms but takes any number.
+// Integers get the index the gettext rule gives them. A number with
+// fraction digits gets its CLDR category and then the index that the
+// gettext rule gives most integers of that category (or, for categories
+// with no integers in them, e.g. Russian "other", the index of a kindred
+// category), since gettext rules only know integers. Lacking CLDR rules
+// for the locale, the integer part of the number is used.
+func GetPluralIdxOperands(forms, locale string, op Operands) (int, error) {
+	var rule PRule
+	if forms != "" {
+		if r, err := ParsePluralForms(forms); err == nil {
+			rule = r
+		}
+	}
+	if rule == nil {
+		r, ok := GetPluralRule(locale)
+		if !ok {
+			return 0, errors.New("could not get idx: invalid locale")
+		}
+		rule = r
+	}
+	if op.IsInt() {
+		return rule.Idx(int(op.I)), nil
+	}
+	rules, ok := cldrRuleSet(cardinalRules, locale)
+	if !ok {
+		return rule.Idx(int(op.I)), nil
+	}
+	return rules.index(rule, rules.category(op)), nil
+}
+
+// cldrDecimalForms names, for locales whose gettext forms don't cover a
+// category that only has fractions in it, the category whose form such
+// numbers take instead: e.g. Russian uses the form for 2..4 with 1.5.
+// Other locales use the form for "other".
+var cldrDecimalForms = map[string]PluralCategory{
+	"be": PluralFew,
+	"pl": PluralFew,
+	"ru": PluralFew,
+	"uk": PluralFew,
+}
+
+var (
+	cardinalRules = compileCLDR(cldrCardinalData, cldrDecimalForms)
+	ordinalRules  = compileCLDR(cldrOrdinalData, nil)
+)
+
+// cldrRules are the rules of one locale for one kind of plurals, tried in
+// order; a number matching none of them is "other"
+type cldrRules struct {
+	locale  string
+	rules   []cldrRule
+	decimal PluralCategory // stands in for categories without integers
+}
+
+type cldrRule struct {
+	cat  PluralCategory
+	cond func(Operands) bool
+}
+
+func (r *cldrRules) category(op Operands) PluralCategory {
+	for _, v := range r.rules {
+		if v.cond(op) {
+			return v.cat
+		}
+	}
+	return PluralOther
+}
+
+var pluralCategories = []PluralCategory{PluralZero, PluralOne, PluralTwo, PluralFew, PluralMany, PluralOther}
+
+// forms lists the categories the rules use, in CLDR order
+func (r *cldrRules) forms() (res []PluralCategory) {
+	for _, c := range pluralCategories {
+		used := c == PluralOther
+		for _, v := range r.rules {
+			used = used || v.cat == c
+		}
+		if used {
+			res = append(res, c)
+		}
+	}
+	return
+}
+
+// gettextIdx caches the category -> index mappings made by index, keyed by
+// locale and gettext rule
+var gettextIdx sync.Map
+
+// index maps a category onto the msgstr index of a gettext rule: the one
+// the rule gives most integers up to 999 of that category
+func (r *cldrRules) index(rule PRule, cat PluralCategory) int {
+	key := r.locale + "\x00" + rule.Header()
+	if m, ok := gettextIdx.Load(key); ok {
+		return m.(map[PluralCategory]int)[cat]
+	}
+	votes := map[PluralCategory]map[int]int{}
+	for n := 0; n < 1000; n++ {
+		c := r.category(intOperands(int64(n)))
+		if votes[c] == nil {
+			votes[c] = map[int]int{}
+		}
+		votes[c][rule.Idx(n)]++
+	}
+	m := map[PluralCategory]int{}
+	for c, counts := range votes {
+		best := -1
+		for idx, ct := range counts {
+			if best < 0 || ct > counts[best] || ct == counts[best] && idx < best {
+				best = idx
+			}
+		}
+		m[c] = best
+	}
+	for _, c := range pluralCategories {
+		if _, ok := m[c]; !ok {
+			if idx, ok := m[r.decimal]; ok {
+				m[c] = idx
+			} else {
+				m[c] = m[PluralOther]
+			}
+		}
+	}
+	gettextIdx.Store(key, m)
+	return m[cat]
+}
+
+func cldrRuleSet(set map[string]*cldrRules, locale string) (*cldrRules, bool) {
+	if r, ok := set[locale]; ok {
+		return r, true
+	}
+	r, ok := set[strings.Split(locale, "_")[0]]
+	return r, ok
+}
+
+// compileCLDR turns rule data keyed by space-separated locales into rule
+// sets keyed by locale. Malformed data is a bug, so it panics.
+func compileCLDR(data map[string]string, decimal map[string]PluralCategory) map[string]*cldrRules {
+	res := map[string]*cldrRules{}
+	for locales, text := range data {
+		var rules []cldrRule
+		for _, part := range strings.Split(text, ";") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			cat, cond, ok := strings.Cut(part, ":")
+			if !ok {
+				panic("cldr rules for " + locales + ": missing category in " + strconv.Quote(part))
+			}
+			fn, err := parseCLDRCondition(cond)
+			if err != nil {
+				panic("cldr rules for " + locales + ": " + err.Error())
+			}
+			rules = append(rules, cldrRule{PluralCategory(strings.TrimSpace(cat)), fn})
+		}
+		for _, locale := range strings.Fields(locales) {
+			res[locale] = &cldrRules{locale: locale, rules: rules, decimal: decimal[locale]}
+		}
+	}
+	return res
+}
+
+// parseCLDRCondition compiles a plural rule condition in the syntax of
+// CLDR, e.g. "v = 0 and i % 10 = 2..4 and i % 100 != 12..14"
+func parseCLDRCondition(s string) (func(Operands) bool, error) {
+	toks := tokenizeCLDR(s)
+	pos := 0
+	next := func() string {
+		if pos < len(toks) {
+			pos++
+			return toks[pos-1]
+		}
+		return ""
+	}
+	peek := func() string {
+		if pos < len(toks) {
+			return toks[pos]
+		}
+		return ""
+	}
+	num := func() (float64, error) {
+		t := next()
+		v, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return 0, fmt.Errorf("condition %q: expected a number, got %q", s, t)
+		}
+		return v, nil
+	}
+	relation := func() (func(Operands) bool, error) {
+		operand := next()
+		get, ok := cldrOperands[operand]
+		if !ok {
+			return nil, fmt.Errorf("condition %q: unknown operand %q", s, operand)
+		}
+		var mod float64
+		if peek() == "%" {
+			next()
+			var err error
+			if mod, err = num(); err != nil {
+				return nil, err
+			}
+		}
+		op := next()
+		if op != "=" && op != "!=" {
+			return nil, fmt.Errorf("condition %q: expected = or !=, got %q", s, op)
+		}
+		var ranges [][2]float64
+		for {
+			lo, err := num()
+			if err != nil {
+				return nil, err
+			}
+			hi := lo
+			if peek() == ".." {
+				next()
+				if hi, err = num(); err != nil {
+					return nil, err
+				}
+			}
+			ranges = append(ranges, [2]float64{lo, hi})
+			if peek() != "," {
+				break
+			}
+			next()
+		}
+		eq := op == "="
+		return func(o Operands) bool {
+			x := get(o)
+			if mod != 0 {
+				x = math.Mod(x, mod)
+			}
+			in := false
+			for _, r := range ranges {
+				in = in || x == math.Trunc(x) && x >= r[0] && x <= r[1]
+			}
+			return in == eq
+		}, nil
+	}
+	and := func() (func(Operands) bool, error) {
+		var conds []func(Operands) bool
+		for {
+			c, err := relation()
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, c)
+			if peek() != "and" {
+				break
+			}
+			next()
+		}
+		return func(o Operands) bool {
+			for _, c := range conds {
+				if !c(o) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	}
+	var conds []func(Operands) bool
+	for {
+		c, err := and()
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, c)
+		if peek() != "or" {
+			break
+		}
+		next()
+	}
+	if pos < len(toks) {
+		return nil, fmt.Errorf("condition %q: unexpected %q", s, toks[pos])
+	}
+	return func(o Operands) bool {
+		for _, c := range conds {
+			if c(o) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+var cldrOperands = map[string]func(Operands) float64{
+	"n": func(o Operands) float64 { return o.N },
+	"i": func(o Operands) float64 { return float64(o.I) },
+	"v": func(o Operands) float64 { return float64(o.V) },
+	"w": func(o Operands) float64 { return float64(o.W) },
+	"f": func(o Operands) float64 { return float64(o.F) },
+	"t": func(o Operands) float64 { return float64(o.T) },
+	"e": func(o Operands) float64 { return float64(o.E) },
+	"c": func(o Operands) float64 { return float64(o.E) },
+}
+
+// tokenizeCLDR splits a condition into words, numbers and the symbols
+// % = != , and ..
+func tokenizeCLDR(s string) (res []string) {
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.HasPrefix(s[i:], "!=") || strings.HasPrefix(s[i:], ".."):
+			res = append(res, s[i:i+2])
+			i += 2
+		case c == '%' || c == '=' || c == ',':
+			res = append(res, s[i:i+1])
+			i++
+		default:
+			j := i + 1
+			for j < len(s) && strings.IndexByte(" \t%=!,.", s[j]) < 0 {
+				j++
+			}
+			res = append(res, s[i:j])
+			i = j
+		}
+	}
+	return
+}
@@ -0,0 +1,137 @@
+package gtspec
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOperands(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Operands
+	}{
+		{"0", Operands{}},
+		{"-3", Operands{N: 3, I: 3}},
+		{"1.0", Operands{N: 1, I: 1, V: 1}},
+		{"1.50", Operands{N: 1.5, I: 1, V: 2, W: 1, F: 50, T: 5}},
+		{"0.05", Operands{N: 0.05, V: 2, W: 2, F: 5, T: 5}},
+		{"1.2c3", Operands{N: 1200, I: 1200, E: 3}},
+		{"1.2345e2", Operands{N: 123.45, I: 123, V: 2, W: 2, F: 45, T: 45, E: 2}},
+	}
+	for _, c := range cases {
+		got, err := ParseOperands(c.in)
+		if err != nil || got != c.want {
+			t.Errorf("%q: expected %+v, got %+v (%v)", c.in, c.want, got, err)
+		}
+	}
+	for _, in := range []string{"", ".5", "1.2.3", "1,5", "abc", "1c"} {
+		if _, err := ParseOperands(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+	if op, err := OperandsOf(2.0); err != nil || !op.IsInt() || op.I != 2 {
+		t.Errorf("expected 2.0 to count as 2, got %+v (%v)", op, err)
+	}
+	if _, err := OperandsOf(true); err == nil {
+		t.Error("expected an error for a bool")
+	}
+}
+
+func TestPluralCategory(t *testing.T) {
+	cases := []struct {
+		locale string
+		n      string
+		want   PluralCategory
+	}{
+		{"en", "1", PluralOne},
+		{"en", "1.0", PluralOther},
+		{"en_GB", "2", PluralOther},
+		{"fr", "1.5", PluralOne},
+		{"fr", "1c6", PluralMany},
+		{"ru", "21", PluralOne},
+		{"ru", "1.5", PluralOther},
+		{"lt", "1.5", PluralMany},
+		{"lv", "0.1", PluralOne},
+		{"lv", "10.0", PluralZero},
+		{"lv", "1.11", PluralZero},
+		{"cy", "6", PluralMany},
+		{"ja", "1", PluralOther},
+	}
+	for _, c := range cases {
+		op, _ := ParseOperands(c.n)
+		if got, ok := GetPluralCategory(c.locale, op); !ok || got != c.want {
+			t.Errorf("%s %s: expected %s, got %s", c.locale, c.n, c.want, got)
+		}
+	}
+	if _, ok := GetPluralCategory("xx", Operands{}); ok {
+		t.Error("expected no rules for xx")
+	}
+}
+
+func TestPluralIdxOperands(t *testing.T) {
+	cases := []struct {
+		forms, locale, n string
+		want             int
+	}{
+		{"", "en", "1", 0},
+		{"", "en", "1.0", 1},
+		{"", "fr", "1.5", 0},
+		{"", "fr", "2.5", 1},
+		{"", "ru", "1.5", 1}, // like 2..4, not 5..20
+		{"", "ru", "21", 0},
+		{"", "pl", "0.5", 1},
+		{"", "lv", "0.1", 0},
+		{"", "lv", "1.11", 1}, // most "zero" integers are not 0
+		{"", "lt", "1.5", 2},
+		{"", "cs", "1.5", 2},
+		{"", "csb", "1.5", 0}, // no CLDR rules: integer part
+		{"nplurals=2; plural=n>5;", "ru", "5", 0},
+	}
+	for _, c := range cases {
+		op, _ := ParseOperands(c.n)
+		if got, err := GetPluralIdxOperands(c.forms, c.locale, op); err != nil || got != c.want {
+			t.Errorf("%s %s: expected %d, got %d (%v)", c.locale, c.n, c.want, got, err)
+		}
+	}
+}
+
+func TestCLDRData(t *testing.T) {
+	seen := map[string]bool{}
+	for locales := range cldrCardinalData {
+		for _, l := range strings.Fields(locales) {
+			if seen[l] {
+				t.Errorf("%s has more than one set of rules", l)
+			}
+			seen[l] = true
+		}
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	cases := []struct {
+		locale string
+		n      int64
+		want   PluralCategory
+		idx    int
+	}{
+		{"en", 1, PluralOne, 0},
+		{"en", 22, PluralTwo, 1},
+		{"en", 13, PluralOther, 3},
+		{"en_US", 103, PluralFew, 2},
+		{"cy", 8, PluralZero, 0},
+		{"it", 11, PluralMany, 0},
+		{"de", 1, PluralOther, 0},
+	}
+	for _, c := range cases {
+		op := intOperands(c.n)
+		if got, _ := GetOrdinalCategory(c.locale, op); got != c.want {
+			t.Errorf("%s %d: expected %s, got %s", c.locale, c.n, c.want, got)
+		}
+		if got := GetOrdinalIdx(c.locale, op); got != c.idx {
+			t.Errorf("%s %d: expected index %d, got %d", c.locale, c.n, c.idx, got)
+		}
+	}
+	if got := GetOrdinalForms("xx"); len(got) != 1 || got[0] != PluralOther {
+		t.Errorf("expected only other for xx, got %v", got)
+	}
+}
@@ -0,0 +1,167 @@
+package gtspec
+
+import (
+	"bufio"
+	"go/build"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EnvProject names the environment variable that, when set, points to the
+// project directory holding POGO.toml and overrides any other location.
+var EnvProject = "POGO_PROJECT"
+
+// NotFoundError reports that no POGO.toml was found for a project path.
+// It matches os.ErrNotExist.
+type NotFoundError struct {
+	Path  string   // path the project was requested by
+	Tried []string // directories searched, in order
+}
+
+func (e *NotFoundError) Error() string {
+	return CFGFN + " for " + strconv.Quote(e.Path) + " not found; tried:\n\t" +
+		strings.Join(e.Tried, "\n\t")
+}
+
+func (e *NotFoundError) Unwrap() error { return os.ErrNotExist }
+
+// LoadOptionsFind loads the configuration of the project at path, which may
+// be absolute or an import path such as "github.com/me/project". If
+// $POGO_PROJECT is set, POGO.toml must be in the directory it names and
+// nowhere else is looked at. Otherwise the first directory containing
+// POGO.toml is used, among:
+//   - path itself, if it is absolute;
+//   - the matching directory of the module enclosing the working directory,
+//     if path lies within that module;
+//   - path under the src directory of each $GOPATH entry;
+//   - the directory of the running executable.
+//
+// If there is none, the error is a *NotFoundError listing them all.
+func LoadOptionsFind(path string) (Config, error) {
+	tried := candidateDirs(path)
+	for _, dir := range tried {
+		if _, err := os.Stat(filepath.Join(dir, CFGFN)); err == nil {
+			return loadOptionsDir(dir)
+		}
+	}
+	return Config{}, &NotFoundError{Path: path, Tried: tried}
+}
+
+// candidateDirs lists the directories LoadOptionsFind searches, in order
+func candidateDirs(path string) (res []string) {
+	if dir := os.Getenv(EnvProject); dir != "" {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		return []string{dir}
+	}
+	if filepath.IsAbs(path) {
+		return append(res, filepath.Clean(path))
+	}
+	if root, mod, ok := findModule(); ok {
+		if path == mod {
+			res = append(res, root)
+		} else if strings.HasPrefix(path, mod+"/") {
+			res = append(res, filepath.Join(root, filepath.FromSlash(path[len(mod)+1:])))
+		}
+	}
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		gopath = build.Default.GOPATH
+	}
+	for _, v := range filepath.SplitList(gopath) {
+		if v != "" {
+			res = append(res, filepath.Join(v, "src", filepath.FromSlash(path)))
+		}
+	}
+	if exe, err := os.Executable(); err == nil {
+		res = append(res, filepath.Dir(exe))
+	}
+	return
+}
+
+// findModule looks for go.mod in the working directory and its parents and
+// returns the directory it was found in along with the module path
+func findModule() (root, mod string, ok bool) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", "", false
+	}
+	return enclosingModule(dir)
+}
+
+// ImportPath returns the import path of the package in dir, going by the
+// go.mod of the module enclosing it or else by its place under the src
+// directory of a $GOPATH entry
+func ImportPath(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	if root, mod, ok := enclosingModule(dir); ok {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return "", false
+		}
+		if rel == "." {
+			return mod, true
+		}
+		return mod + "/" + filepath.ToSlash(rel), true
+	}
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		gopath = build.Default.GOPATH
+	}
+	for _, v := range filepath.SplitList(gopath) {
+		if v == "" {
+			continue
+		}
+		rel, err := filepath.Rel(filepath.Join(v, "src"), dir)
+		if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel), true
+		}
+	}
+	return "", false
+}
+
+// enclosingModule looks for go.mod in dir and its parents
+func enclosingModule(dir string) (root, mod string, ok bool) {
+	for {
+		if mod, ok = modulePath(filepath.Join(dir, "go.mod")); ok {
+			return dir, mod, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
+
+// modulePath returns the path declared by the module directive of a
+// go.mod file
+func modulePath(fn string) (string, bool) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "module" {
+			continue
+		}
+		if s, err := strconv.Unquote(fields[1]); err == nil {
+			return s, true
+		}
+		return fields[1], true
+	}
+	return "", false
+}
@@ -0,0 +1,342 @@
+package gtspec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PRExpr is a pluralization rule given by the Plural-Forms header of a
+// catalog, e.g. "nplurals=2; plural=(n != 1);", rather than by one of the
+// hand-written rules above
+type PRExpr struct {
+	Lang     string
+	NPlurals int
+	Plural   string // the C expression, as written
+	eval     pluralFn
+}
+
+func (r PRExpr) Name() string { return r.Lang }
+
+// Idx evaluates the expression for n. As with gettext, an index the
+// catalog has no form for yields the first form.
+func (r PRExpr) Idx(n int) int {
+	idx := r.eval(int64(n))
+	if idx < 0 || idx >= int64(r.NPlurals) {
+		return 0
+	}
+	return int(idx)
+}
+
+func (r PRExpr) Header() string {
+	return fmt.Sprintf("nplurals=%d; plural=%s;", r.NPlurals, r.Plural)
+}
+
+var (
+	pluralsMu   sync.RWMutex // guards Plurals once init is done
+	parsedForms sync.Map     // Plural-Forms header -> PRExpr or error
+)
+
+// ParsePluralForms parses the value of a Plural-Forms header.
+func ParsePluralForms(forms string) (PRExpr, error) {
+	if v, ok := parsedForms.Load(forms); ok {
+		if err, ok := v.(error); ok {
+			return PRExpr{}, err
+		}
+		return v.(PRExpr), nil
+	}
+	r, err := parsePluralForms(forms)
+	if err != nil {
+		parsedForms.Store(forms, err)
+		return PRExpr{}, err
+	}
+	parsedForms.Store(forms, r)
+	return r, nil
+}
+
+func parsePluralForms(forms string) (r PRExpr, err error) {
+	var nplurals string
+	for _, field := range strings.Split(forms, ";") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case "nplurals":
+			nplurals = strings.TrimSpace(kv[1])
+		case "plural":
+			r.Plural = strings.TrimSpace(kv[1])
+		}
+	}
+	if r.NPlurals, err = strconv.Atoi(nplurals); err != nil || r.NPlurals < 1 {
+		return PRExpr{}, fmt.Errorf("plural forms %q: bad nplurals", forms)
+	}
+	if r.Plural == "" {
+		return PRExpr{}, fmt.Errorf("plural forms %q: no plural expression", forms)
+	}
+	p := &exprParser{src: r.Plural}
+	if r.eval, err = p.parse(); err != nil {
+		return PRExpr{}, fmt.Errorf("plural forms %q: %v", forms, err)
+	}
+	return r, nil
+}
+
+// RegisterPlural adds or replaces the pluralization rule for a locale, as
+// given by a Plural-Forms header, so that locales missing from Plurals
+// can be supported without patching it. It is safe to call while
+// translators are in use.
+func RegisterPlural(locale, forms string) error {
+	r, err := ParsePluralForms(forms)
+	if err != nil {
+		return err
+	}
+	r.Lang = locale
+	pluralsMu.Lock()
+	defer pluralsMu.Unlock()
+	Plurals[locale] = r
+	return nil
+}
+
+// GetPluralRule returns the pluralization rule for a locale, or for its
+// base language if the locale has none of its own
+func GetPluralRule(locale string) (PRule, bool) {
+	pluralsMu.RLock()
+	defer pluralsMu.RUnlock()
+	if r, ok := Plurals[locale]; ok {
+		return r, true
+	}
+	r, ok := Plurals[strings.Split(locale, "_")[0]]
+	return r, ok
+}
+
+// GetPluralIdxForms is like GetPluralIdx but follows the Plural-Forms
+// header of a catalog, if it has a valid one.
+func GetPluralIdxForms(forms, locale string, ct int) (int, error) {
+	if forms != "" {
+		if r, err := ParsePluralForms(forms); err == nil {
+			return r.Idx(ct), nil
+		}
+	}
+	return GetPluralIdx(locale, ct)
+}
+
+// pluralFn is a compiled plural expression
+type pluralFn func(n int64) int64
+
+func cbool(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// exprParser compiles the C subset used by plural expressions: the
+// variable n, decimal numbers, parentheses, the operators ! * / % + - < <=
+// > >= == != && || and the conditional operator ?:, with C precedence
+type exprParser struct {
+	src string
+	pos int
+}
+
+func (p *exprParser) parse() (fn pluralFn, err error) {
+	defer func() {
+		switch e := recover().(type) {
+		case nil:
+		case exprError:
+			fn, err = nil, e
+		default:
+			panic(e)
+		}
+	}()
+	fn = p.ternary()
+	if p.skip(); p.pos < len(p.src) {
+		p.fail("unexpected %q", p.src[p.pos:])
+	}
+	return fn, nil
+}
+
+type exprError string
+
+func (e exprError) Error() string { return string(e) }
+
+func (p *exprParser) fail(format string, a ...interface{}) {
+	panic(exprError(fmt.Sprintf("at %d: ", p.pos) + fmt.Sprintf(format, a...)))
+}
+
+func (p *exprParser) skip() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// accept consumes the first of ops found next in the input and returns
+// it, or "" if there is none. Longer operators must come first.
+func (p *exprParser) accept(ops ...string) string {
+	p.skip()
+	for _, op := range ops {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *exprParser) ternary() pluralFn {
+	cond := p.or()
+	if p.accept("?") == "" {
+		return cond
+	}
+	a := p.ternary()
+	if p.accept(":") == "" {
+		p.fail("expected ':'")
+	}
+	b := p.ternary()
+	return func(n int64) int64 {
+		if cond(n) != 0 {
+			return a(n)
+		}
+		return b(n)
+	}
+}
+
+func (p *exprParser) or() pluralFn {
+	x := p.and()
+	for p.accept("||") != "" {
+		a, b := x, p.and()
+		x = func(n int64) int64 { return cbool(a(n) != 0 || b(n) != 0) }
+	}
+	return x
+}
+
+func (p *exprParser) and() pluralFn {
+	x := p.equality()
+	for p.accept("&&") != "" {
+		a, b := x, p.equality()
+		x = func(n int64) int64 { return cbool(a(n) != 0 && b(n) != 0) }
+	}
+	return x
+}
+
+func (p *exprParser) equality() pluralFn {
+	x := p.relational()
+	for {
+		op := p.accept("==", "!=")
+		if op == "" {
+			return x
+		}
+		a, b := x, p.relational()
+		if op == "==" {
+			x = func(n int64) int64 { return cbool(a(n) == b(n)) }
+		} else {
+			x = func(n int64) int64 { return cbool(a(n) != b(n)) }
+		}
+	}
+}
+
+func (p *exprParser) relational() pluralFn {
+	x := p.additive()
+	for {
+		op := p.accept("<=", ">=", "<", ">")
+		if op == "" {
+			return x
+		}
+		a, b := x, p.additive()
+		switch op {
+		case "<=":
+			x = func(n int64) int64 { return cbool(a(n) <= b(n)) }
+		case ">=":
+			x = func(n int64) int64 { return cbool(a(n) >= b(n)) }
+		case "<":
+			x = func(n int64) int64 { return cbool(a(n) < b(n)) }
+		case ">":
+			x = func(n int64) int64 { return cbool(a(n) > b(n)) }
+		}
+	}
+}
+
+func (p *exprParser) additive() pluralFn {
+	x := p.multiplicative()
+	for {
+		op := p.accept("+", "-")
+		if op == "" {
+			return x
+		}
+		a, b := x, p.multiplicative()
+		if op == "+" {
+			x = func(n int64) int64 { return a(n) + b(n) }
+		} else {
+			x = func(n int64) int64 { return a(n) - b(n) }
+		}
+	}
+}
+
+func (p *exprParser) multiplicative() pluralFn {
+	x := p.unary()
+	for {
+		op := p.accept("*", "/", "%")
+		if op == "" {
+			return x
+		}
+		a, b := x, p.unary()
+		switch op {
+		case "*":
+			x = func(n int64) int64 { return a(n) * b(n) }
+		case "/":
+			x = func(n int64) int64 {
+				if d := b(n); d != 0 {
+					return a(n) / d
+				}
+				return 0
+			}
+		case "%":
+			x = func(n int64) int64 {
+				if d := b(n); d != 0 {
+					return a(n) % d
+				}
+				return 0
+			}
+		}
+	}
+}
+
+func (p *exprParser) unary() pluralFn {
+	switch p.accept("!", "-") {
+	case "!":
+		a := p.unary()
+		return func(n int64) int64 { return cbool(a(n) == 0) }
+	case "-":
+		a := p.unary()
+		return func(n int64) int64 { return -a(n) }
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() pluralFn {
+	if p.accept("(") != "" {
+		x := p.ternary()
+		if p.accept(")") == "" {
+			p.fail("expected ')'")
+		}
+		return x
+	}
+	if p.accept("n") != "" {
+		return func(n int64) int64 { return n }
+	}
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		if p.pos == len(p.src) {
+			p.fail("unexpected end of expression")
+		}
+		p.fail("unexpected %q", p.src[p.pos:])
+	}
+	v, err := strconv.ParseInt(p.src[start:p.pos], 10, 64)
+	if err != nil {
+		p.fail("%v", err)
+	}
+	return func(int64) int64 { return v }
+}
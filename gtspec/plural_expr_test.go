@@ -0,0 +1,85 @@
+package gtspec
+
+import "testing"
+
+// Every hand-written rule should agree with its own Plural-Forms header.
+func TestPluralHeaders(t *testing.T) {
+	for locale, rule := range Plurals {
+		expr, err := ParsePluralForms(rule.Header())
+		if err != nil {
+			t.Errorf("%s: %v", locale, err)
+			continue
+		}
+		for n := 0; n <= 1000; n++ {
+			if a, b := rule.Idx(n), expr.Idx(n); a != b {
+				t.Errorf("%s (%s): n=%d: rule gives %d, header %d", locale, rule.Header(), n, a, b)
+				break
+			}
+		}
+	}
+}
+
+func TestParsePluralForms(t *testing.T) {
+	cases := []struct {
+		forms string
+		want  []int // indices for n = 0, 1, 2, ...
+	}{
+		{"nplurals=1; plural=0;", []int{0, 0, 0}},
+		{"nplurals=2; plural=n != 1;", []int{1, 0, 1, 1}},
+		{"nplurals=2; plural=(n > 1)", []int{0, 0, 1}},
+		{"nplurals=3; plural=n==0 ? 0 : n==1 ? 1 : 2;", []int{0, 1, 2, 2}},
+		{"nplurals=2; plural=!(n == 1);", []int{1, 0, 1}},
+		{"nplurals=3; plural=(n + 1) * 2 / 2 - 1 % 3;", []int{0, 1, 2, 0}}, // out of range -> 0
+		{"nplurals=2; plural=n / (n - 1);", []int{0, 0, 0, 1}},              // division by zero -> 0
+	}
+	for _, c := range cases {
+		r, err := ParsePluralForms(c.forms)
+		if err != nil {
+			t.Errorf("%q: %v", c.forms, err)
+			continue
+		}
+		for n, want := range c.want {
+			if got := r.Idx(n); got != want {
+				t.Errorf("%q: n=%d: expected %d, got %d", c.forms, n, want, got)
+			}
+		}
+	}
+
+	for _, forms := range []string{
+		"",
+		"plural=n != 1;",
+		"nplurals=0; plural=0;",
+		"nplurals=2;",
+		"nplurals=2; plural=(n != 1;",
+		"nplurals=2; plural=n ? 1;",
+		"nplurals=2; plural=n != ;",
+		"nplurals=2; plural=x;",
+	} {
+		if _, err := ParsePluralForms(forms); err == nil {
+			t.Errorf("%q: expected an error", forms)
+		}
+	}
+}
+
+func TestRegisterPlural(t *testing.T) {
+	if err := RegisterPlural("xx_YY", "nplurals=3; plural=n%3;"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		pluralsMu.Lock()
+		delete(Plurals, "xx_YY")
+		pluralsMu.Unlock()
+	}()
+	if idx, err := GetPluralIdx("xx_YY", 5); err != nil || idx != 2 {
+		t.Errorf("expected 2, got %d (%v)", idx, err)
+	}
+	if n := GetPluralNum("xx_YY"); n != 3 {
+		t.Errorf("expected 3 forms, got %d", n)
+	}
+	if err := RegisterPlural("xx", "nplurals=3"); err == nil {
+		t.Error("expected an error for a header without an expression")
+	}
+	if idx, err := GetPluralIdxForms("nplurals=2; plural=n>5;", "en", 3); err != nil || idx != 0 {
+		t.Errorf("expected the header to win, got %d (%v)", idx, err)
+	}
+}
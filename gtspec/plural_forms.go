@@ -406,10 +406,10 @@ func (r PRGD) Idx(n int) int {
 		return 0
 	case n == 2 || n == 12:
 		return 1
-	case n < 2 && n < 20:
+	case n > 2 && n < 20:
 		return 2
 	default:
-		return 4
+		return 3
 	}
 	return 3 // unreachable
 }
@@ -446,7 +446,7 @@ func (r PRLT) Idx(n int) int {
 	switch {
 	case n%10 == 1 && n%100 != 11:
 		return 0
-	case n%10 == 2 && (n%100 < 10 || n%100 >= 20):
+	case n%10 >= 2 && (n%100 < 10 || n%100 >= 20):
 		return 1
 	default:
 		return 2
@@ -3,8 +3,10 @@ package gtspec
 import (
 	"errors"
 	"github.com/Sam-Izdat/pogo/deps/toml"
+	"io/fs"
 	"io/ioutil"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 )
@@ -26,6 +28,7 @@ type Msg struct {
 	Comments  CommentPack
 	Filename  string // Name of file extracted from (to be shoved into comments)
 	Line      int    // Line number within file (to be shoved into comment)
+	Obsolete  bool   // Entry is commented out ("#~") in its catalog
 }
 
 type Config struct {
@@ -36,12 +39,14 @@ type Config struct {
 }
 
 type confGeneral struct {
-	ProjectName string `toml:"project_name"`
-	ProjectFN   string `toml:"project_filename"`
-	Targets     []string
-	DirProject  string
-	DirLocale   string `toml:"dir_locale"`
-	DirMessages string `toml:"dir_messages"`
+	ProjectName   string `toml:"project_name"`
+	ProjectFN     string `toml:"project_filename"`
+	Targets       []string
+	DefaultLocale string `toml:"default_locale"`
+	DirProject    string
+	DirLocale     string `toml:"dir_locale"`
+	DirMessages   string `toml:"dir_messages"`
+	PreferPo      bool   `toml:"prefer_po"`
 }
 
 type confParsing struct {
@@ -50,8 +55,19 @@ type confParsing struct {
 	FuncNG   string   `toml:"function_ngettext"`
 	FuncPG   string   `toml:"function_pgettext"`
 	FuncNPG  string   `toml:"function_npgettext"`
+	FuncOG   string   `toml:"function_ogettext"`
+	FuncPOG  string   `toml:"function_pogettext"`
 	DelimL   string   `toml:"delimiter_left"`
 	DelimR   string   `toml:"delimiter_right"`
+	Tag      string   `toml:"comment_tag"`
+	FmtFlag  string   `toml:"format_flag"`
+	Typed    bool     `toml:"type_check"`
+	Wrappers map[string]string
+	Strict   bool
+	Include  []string
+	Exclude  []string
+	SkipTest bool `toml:"skip_tests"`
+	SkipGen  bool `toml:"skip_generated"`
 }
 
 type confPo struct {
@@ -101,7 +117,6 @@ func LoadOptions() (Config, error) {
 }
 
 func LoadOptionsGOPATH(path string) (Config, error) {
-	var options Config
 	if path[0:1] != ps {
 		// If the path is relative (does not beggin with a path separator)
 		// try to obtain the $GOPATH environment variable. Otherwise,
@@ -109,6 +124,13 @@ func LoadOptionsGOPATH(path string) (Config, error) {
 		gopath := os.Getenv("GOPATH")
 		path = gopath + ps + "src" + ps + path
 	}
+	return loadOptionsDir(path)
+}
+
+// loadOptionsDir loads the configuration from the POGO.toml file in the
+// project directory path
+func loadOptionsDir(path string) (Config, error) {
+	var options Config
 	data, err := ioutil.ReadFile(path + ps + CFGFN)
 	if err != nil {
 		return Config{}, err
@@ -122,17 +144,33 @@ func LoadOptionsGOPATH(path string) (Config, error) {
 	return options, nil
 }
 
+// LoadOptionsFS loads the configuration from the POGO.toml file at the
+// root of fsys, e.g. an embed.FS. %PROJECT% in dir_locale stands for that
+// root, and the resulting DirLocale is a slash-separated path within fsys.
+func LoadOptionsFS(fsys fs.FS) (Config, error) {
+	var options Config
+	data, err := fs.ReadFile(fsys, CFGFN)
+	if err != nil {
+		return Config{}, err
+	}
+	if _, err := toml.Decode(string(data), &options); err != nil {
+		return Config{}, err
+	}
+	options.General.DirProject = "."
+	ldir := strings.Replace(options.General.DirLocale, "\\", "/", -1)
+	ldir = path.Clean(strings.Replace(ldir, "%PROJECT%", ".", -1))
+	if !fs.ValidPath(ldir) {
+		return Config{}, errors.New("dir_locale is not a path within the file system: " + ldir)
+	}
+	options.General.DirLocale = ldir
+	return options, nil
+}
+
 // GetPluralIdx returns the index of a plural translation,
 // determined by locale and count
 func GetPluralIdx(locale string, ct int) (int, error) {
-	var ok bool
-	if _, ok = Plurals[locale]; ok {
-		return Plurals[locale].Idx(ct), nil
-	} else {
-		lang := strings.Split(locale, "_")[0]
-		if _, ok = Plurals[lang]; ok {
-			return Plurals[lang].Idx(ct), nil
-		}
+	if r, ok := GetPluralRule(locale); ok {
+		return r.Idx(ct), nil
 	}
 	return 0, errors.New("could not get idx: invalid locale")
 }
@@ -141,14 +179,8 @@ func GetPluralIdx(locale string, ct int) (int, error) {
 // or 2 on failure
 func GetPluralNum(locale string) int {
 	var header string
-	var ok bool
-	if _, ok = Plurals[locale]; ok {
-		header = Plurals[locale].Header()
-	} else {
-		lang := strings.Split(locale, "_")[0]
-		if _, ok = Plurals[lang]; ok {
-			header = Plurals[lang].Header()
-		}
+	if r, ok := GetPluralRule(locale); ok {
+		header = r.Header()
 	}
 
 	if header != "" {
@@ -6,6 +6,7 @@ import (
     "fmt"
     spec "github.com/Sam-Izdat/pogo/gtspec"
     "github.com/Sam-Izdat/pogo/po"
+    "github.com/Sam-Izdat/pogo/catalog"
     "github.com/Sam-Izdat/pogo/deps/odin/cli"
     "time"
     "strings"
@@ -22,9 +23,15 @@ var (
 
 func init() {
     cmdInit  = CLI.DefineSubCommand("init", "initialize pogo in this directory", pinit)
-    cmdBuild = CLI.DefineSubCommand("build", "scan source and compile .pot or .po files", build, "filetype")
-    cmdBuild.DefineBoolFlag("overwrite", false, "overwrite existing files")
+    cmdBuild = CLI.DefineSubCommand("build", "scan source and compile .pot, .po or .mo files", build, "filetype")
+    cmdBuild.DefineBoolFlag("overwrite", false, "overwrite an existing .pot file")
     cmdBuild.AliasFlag('o', "overwrite")
+    cmdBuild.DefineBoolFlag("fuzzy", false, "include fuzzy translations in .mo files")
+    cmdBuild.AliasFlag('f', "fuzzy")
+    cmdBuild.DefineBoolFlag("strict", false, "fail if any source file can't be scanned")
+    cmdBuild.AliasFlag('s', "strict")
+    cmdBuild.DefineBoolFlag("no-cache", false, "scan every source file, not just those changed since the last scan")
+    cmdBuild.AliasFlag('n', "no-cache")
 }
 
 func main() {
@@ -66,7 +73,7 @@ func build(c cli.Command) {
     loadOptions()
     if c.Params()["filetype"] == nil {
         fmt.Println("\n", pWarn, "missing filetype parameter \n", 
-            `Specify what to build ("pot"/"po") - e.g. "pogo build pot"`)
+            `Specify what to build ("pot"/"po"/"mo") - e.g. "pogo build pot"`)
         os.Exit(1)
     }
 
@@ -84,12 +91,7 @@ func build(c cli.Command) {
 
         // Scan
         defer un(trace("scan/build"))
-        fmt.Println("Parsing...")
-        pdir := o.General.DirProject
-        msgs := []spec.Msg{}
-        msgs = append(po.ScanGo(pdir), po.ScanTmpl(pdir)...)
-        po.RemoveDuplicates(&msgs)
-        fmt.Println(len(msgs), "unique message(s) extracted")
+        msgs := scan(c)
         fmt.Println("Compiling", o.General.ProjectFN+".pot...")
 
         // Write
@@ -101,21 +103,8 @@ func build(c cli.Command) {
         verifyLocaleDir()
 
         defer un(trace("scan/build"))
-        fmt.Println("Parsing...")
-        pdir := o.General.DirProject
-        msgs := []spec.Msg{}
-        msgs = append(po.ScanGo(pdir), po.ScanTmpl(pdir)...)
-        po.RemoveDuplicates(&msgs)
-        fmt.Println(len(msgs), "unique message(s) extracted")
-
-        if c.Flag("overwrite").Get() == true {
-            fmt.Println(pNotice, fStr("WARNING!").s("bold"))
-            fmt.Println(fStr("OVERWRITING EXISTING CATALOGS").s("red"))
-            fmt.Println("You will be asked to confirm overwrites individually.")
-            fmt.Println("This is a destructive and not an additive process.")
-            fmt.Println("Any completed translations in these files will be", fStr("LOST!").s("bold"))
-        }
-        
+        msgs := scan(c)
+
         for _, target := range o.General.Targets {
             path := o.General.DirLocale+ps+target+ps+o.General.DirMessages
             dir, err := os.Stat(path)
@@ -127,35 +116,87 @@ func build(c cli.Command) {
                 }
             }
             file := o.General.ProjectFN+"."+target+".po"
-            fn := path+ps+file
-            if _, err := os.Stat(fn); err == nil {
-                if c.Flag("overwrite").Get() == true {
-                    var confirm string
-                    fmt.Print("Are you sure you want to overwrite ", file, "? (y/N) : ")
-                    fmt.Scanln(&confirm)
-                    if confirm != "y" && confirm != "Y" {
-                        continue
-                    }
-                } else {
-                    fmt.Println(pNotice, fStr("skipping").s("bold"), file,
-                        `- file already exists; use "-o" flag to overwrite`)
-                    continue                    
-                }
+            if _, err := os.Stat(path+ps+file); err == nil {
+                fmt.Println("Merging", file+"...")
+            } else {
+                fmt.Println("Compiling", file+"...")
+            }
+
+            // Write
+            stats, err := WritePO(msgs, target, path)
+            if err != nil {
+                fmt.Println(pWarn, "ERROR compiling", file, "-", err)
+                continue
+            }
+            fmt.Printf("  %s: %d kept, %d fuzzy, %d new, %d obsolete\n",
+                target, stats.Kept, stats.Fuzzy, stats.Added, stats.Obsolete)
+        }
+
+    case "mo":
+        verifyLocaleDir()
+
+        defer un(trace("build"))
+        for _, target := range o.General.Targets {
+            path := o.General.DirLocale+ps+target+ps+o.General.DirMessages
+            file := o.General.ProjectFN+"."+target+".po"
+            if _, err := os.Stat(path+ps+file); err != nil {
+                fmt.Println(pNotice, fStr("skipping").s("bold"), file,
+                    `- catalog does not exist; run "pogo build po" first`)
+                continue
             }
-            fmt.Println("Compiling", file+"...")
+            fmt.Println("Compiling", o.General.ProjectFN+"."+target+".mo...")
 
             // Write
-            err = WritePO(msgs, target, path)
+            stats, err := WriteMO(target, path, c.Flag("fuzzy").Get() == true)
             if err != nil {
                 fmt.Println(pWarn, "ERROR compiling", file, "-", err)
+                continue
             }
+            fmt.Printf("  %s: %d translated, %d fuzzy, %d untranslated\n",
+                target, stats.Translated, stats.Fuzzy, stats.Untranslated)
         }
 
     default: 
         fmt.Println(pWarn, "invalid filetype parameter \n", 
-            `Expecting "pot" or "po" - e.g. "pogo build pot"`)
+            `Expecting "pot", "po" or "mo" - e.g. "pogo build pot"`)
+        os.Exit(1)
+    }
+}
+
+// scan extracts the messages of the project, reporting any problems with
+// the source on the way, and gives up if there were errors in strict mode
+func scan(c cli.Command) []spec.Msg {
+    pdir := o.General.DirProject
+    errs, warns := 0, 0
+    po.Report = func(d po.Diagnostic) {
+        d.Pos.Filename = strings.TrimPrefix(d.Pos.Filename, pdir+ps)
+        if d.Err {
+            errs++
+            fmt.Println(pWarn, d)
+        } else {
+            warns++
+            fmt.Println(pNotice, d)
+        }
+    }
+
+    if c.Flag("no-cache").Get() == false {
+        if dir, err := os.UserCacheDir(); err == nil {
+            po.CacheDir = dir + ps + "pogo"
+        }
+    }
+
+    fmt.Println("Parsing...")
+    msgs := append(po.ScanGo(pdir), po.ScanTmpl(pdir)...)
+    po.RemoveDuplicates(&msgs)
+    fmt.Println(len(msgs), "unique message(s) extracted")
+    if errs > 0 || warns > 0 {
+        fmt.Printf("%d error(s), %d warning(s)\n", errs, warns)
+    }
+    if errs > 0 && (o.Parsing.Strict || c.Flag("strict").Get() == true) {
+        fmt.Println(pWarn, fStr("aborting").s("bold"), "- some source could not be scanned")
         os.Exit(1)
     }
+    return msgs
 }
 
 func loadOptions() {
@@ -178,7 +219,7 @@ func verifyLocaleDir() {
 }
 
 func WritePOT(msgs []spec.Msg) error {
-    pofile := po.Compile(msgs, "", "", "")    
+    pofile := po.Compile(nil, po.ExpandOrdinals(msgs, ""), "", "", "")    
 
     // open output file
     fn := o.General.DirLocale+ps+o.General.ProjectFN+".pot"
@@ -202,36 +243,70 @@ func WritePOT(msgs []spec.Msg) error {
     return nil
 }
 
-func WritePO(msgs []spec.Msg, target string, path string) error {
-    prule := spec.Plurals[target]
-    if prule == nil {
-        prule = spec.Plurals[strings.Split(target, "_")[0]] 
-        if prule == nil {
-            return errors.New("unknown locale")
+// WritePO writes the catalog for target, merging msgs into the existing
+// catalog, if there is one, so that no translations are lost
+func WritePO(msgs []spec.Msg, target string, path string) (po.MergeStats, error) {
+    prule, ok := spec.GetPluralRule(target)
+    if !ok {
+        return po.MergeStats{}, errors.New("unknown locale")
+    }
+
+    fn := path+ps+o.General.ProjectFN+"."+target+".po"
+    var def []spec.Msg
+    if _, err := os.Stat(fn); err == nil {
+        var err error
+        def, err = catalog.ParseFile(fn)
+        if err != nil {
+            return po.MergeStats{}, err
         }
     }
+    msgs, header, stats := po.Merge(def, po.ExpandOrdinals(msgs, target))
 
     name := prule.Name()
     pf := prule.Header()
-    pofile := po.Compile(msgs, target, name, pf)
-   
-    // open output file
-    fn := path+ps+o.General.ProjectFN+"."+target+".po"
-    fo, err := os.Create(fn)
-    if err != nil {
+    pofile := po.Compile(header, msgs, target, name, pf)
+    err := writeFile(fn, func(fo *os.File) error {
+        _, err := fo.Write([]byte(pofile))
         return err
+    })
+    return stats, err
+}
+
+func WriteMO(target string, path string, fuzzy bool) (catalog.MoStats, error) {
+    base := path+ps+o.General.ProjectFN+"."+target
+    msgs, err := catalog.ParseFile(base+".po")
+    if err != nil {
+        return catalog.MoStats{}, err
     }
 
-    // close fo on exit and check for its returned error
-    defer func() error {
-        if err := fo.Close(); err != nil {
-            return err
-        }
-        return nil
-    }()
+    var stats catalog.MoStats
+    err = writeFile(base+".mo", func(fo *os.File) (err error) {
+        stats, err = catalog.CompileMo(fo, msgs, fuzzy)
+        return
+    })
+    return stats, err
+}
 
-    // write a chunk
-    if _, err := fo.Write([]byte(pofile)); err != nil {
+// writeFile writes fn by way of a temporary file in the same directory,
+// which only takes its place once written and closed without error, so
+// that a failed build leaves the last good file as it was
+func writeFile(fn string, write func(*os.File) error) error {
+    tmp := fn+".tmp"
+    fo, err := os.Create(tmp)
+    if err != nil {
+        return err
+    }
+    if err := write(fo); err != nil {
+        fo.Close()
+        os.Remove(tmp)
+        return err
+    }
+    if err := fo.Close(); err != nil {
+        os.Remove(tmp)
+        return err
+    }
+    if err := os.Rename(tmp, fn); err != nil {
+        os.Remove(tmp)
         return err
     }
     return nil
@@ -0,0 +1,143 @@
+package po
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	spec "github.com/Sam-Izdat/pogo/gtspec"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheDir is where the scanners keep what they found in each file, so that
+// files unchanged since the last scan needn't be parsed again. Nothing is
+// cached if it is empty.
+var CacheDir string
+
+// cacheVersion is bumped whenever what goes into the cache changes
+const cacheVersion = 2
+
+// cacheEntry is what was found scanning a directory of Go files or a
+// template, along with the content hashes of the files it depends on: the
+// files scanned and, for Go, the files of the packages they import.
+type cacheEntry struct {
+	Files map[string]string
+	Msgs  []spec.Msg
+	Diags diagnostics
+}
+
+// cache holds the entries of the last scan of a project by the directory
+// or template they are for, and collects those of this one
+type cache struct {
+	Version int
+	Config  string
+	Entries map[string]*cacheEntry
+
+	fn     string
+	fresh  map[string]*cacheEntry
+	hashes sync.Map // file -> content hash, of files not scanned
+}
+
+// openCache loads what was cached the last time files of a kind, "go" or
+// "tmpl", were scanned in the project at root. An entry is of no use if the
+// settings affecting the scanners have changed since, so then, or if the
+// cache can't be read, scanning starts afresh.
+func openCache(root, kind string) *cache {
+	c := &cache{fresh: make(map[string]*cacheEntry)}
+	if CacheDir == "" {
+		return c
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return c
+	}
+	key := hashBytes([]byte(abs + "\x00" + kind))
+	c.fn = filepath.Join(CacheDir, key[:16]+".json")
+	buf, err := os.ReadFile(c.fn)
+	config := cacheConfig()
+	if err != nil || json.Unmarshal(buf, c) != nil || c.Version != cacheVersion || c.Config != config {
+		c.Entries = nil
+	}
+	c.Version, c.Config = cacheVersion, config
+	return c
+}
+
+// cacheConfig sums up the settings affecting what the scanners find
+func cacheConfig() string {
+	return hashBytes([]byte(fmt.Sprint(gf, ngf, pgf, npgf, ogf, pogf, lDelim, rDelim, tag,
+		typed, typedFuncs, o.Parsing)))
+}
+
+// lookup returns the entry for key if the files it was made from are as
+// they were, given the hashes of the files scanned now, or else nil
+func (c *cache) lookup(key string, files map[string]string) *cacheEntry {
+	e := c.Entries[key]
+	if e == nil {
+		return nil
+	}
+	scanned := 0
+	for fn, sum := range e.Files {
+		now, ok := files[fn]
+		if ok {
+			scanned++
+		} else {
+			now = c.hash(fn)
+		}
+		if now != sum {
+			return nil
+		}
+	}
+	if scanned != len(files) {
+		return nil
+	}
+	return e
+}
+
+// hash returns the content hash of a file that isn't itself scanned, only
+// hashing it once however many entries depend on it
+func (c *cache) hash(fn string) string {
+	if sum, ok := c.hashes.Load(fn); ok {
+		return sum.(string)
+	}
+	var sum string
+	if buf, err := os.ReadFile(fn); err == nil {
+		sum = hashBytes(buf)
+	}
+	c.hashes.Store(fn, sum)
+	return sum
+}
+
+// store keeps an entry for the next scan
+func (c *cache) store(key string, e *cacheEntry) {
+	c.fresh[key] = e
+}
+
+// save writes out the entries stored, replacing those of the last scan.
+// Failing that, the next scan just doesn't find them.
+func (c *cache) save() {
+	if c.fn == "" {
+		return
+	}
+	c.Entries = c.fresh
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(CacheDir, 0755); err != nil {
+		return
+	}
+	tmp := c.fn + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0644); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, c.fn); err != nil {
+		os.Remove(tmp)
+	}
+}
+
+func hashBytes(buf []byte) string {
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
@@ -11,8 +11,8 @@ func drawComments(comments spec.CommentPack) string {
 	var lines []string
 	ref := []spec.CommentSpec{
 		{"translator", "# "},
-		{"reference", "#: "},
 		{"extracted", "#. "},
+		{"reference", "#: "},
 		{"flag", "#, "},
 		{"previous", "#| "},
 	}
@@ -31,6 +31,7 @@ func drawComments(comments spec.CommentPack) string {
 func drawBlock(msg spec.Msg, target string) string {
 	var (
 		response    []string
+		keywords    []string
 		comments    spec.CommentPack = msg.Comments
 		msgctxt     string           = msg.Ctxt
 		msgid       string           = msg.Id
@@ -38,34 +39,64 @@ func drawBlock(msg spec.Msg, target string) string {
 		msgstr      string           = msg.Str
 	)
 
+	// obsolete entries keep their comments, but "previous" ones are
+	// commented out along with the rest of the entry
+	var previous []string
+	if msg.Obsolete && len(comments["previous"]) > 0 {
+		previous = comments["previous"]
+		comments = make(spec.CommentPack)
+		for k, v := range msg.Comments {
+			if k != "previous" {
+				comments[k] = v
+			}
+		}
+	}
+
 	if len(comments) > 0 {
 		commentsStr := drawComments(comments)
 		if len(commentsStr) > 0 {
 			response = append(response, commentsStr)
 		}
 	}
+	for _, v := range previous {
+		response = append(response, "#~| "+v)
+	}
 
 	if len(msgctxt) > 0 {
-		response = append(response, addPOString("msgctxt", msgctxt))
+		keywords = append(keywords, addPOString("msgctxt", msgctxt))
 	}
 
-	response = append(response, addPOString("msgid", msgid))
+	keywords = append(keywords, addPOString("msgid", msgid))
 
 	if len(msgidPlural) > 0 {
-		response = append(response, addPOString("msgid_plural", msgidPlural))
-		if target == "" {
-			response = append(response, addPOString("msgstr[0]", ""))
-			response = append(response, addPOString("msgstr[1]", ""))
-		} else {
-			nplurals := spec.GetPluralNum(target)
-			for i := 0; i < nplurals; i++ {
-				response = append(response, addPOString("msgstr["+strconv.Itoa(i)+"]", ""))
+		keywords = append(keywords, addPOString("msgid_plural", msgidPlural))
+		nplurals := 2
+		if target != "" {
+			nplurals = spec.GetPluralNum(target)
+		}
+		if len(msg.StrPlural) > nplurals {
+			nplurals = len(msg.StrPlural)
+		}
+		for i := 0; i < nplurals; i++ {
+			var str string
+			if i < len(msg.StrPlural) {
+				str = msg.StrPlural[i]
 			}
+			keywords = append(keywords, addPOString("msgstr["+strconv.Itoa(i)+"]", str))
 		}
 	} else {
-		response = append(response, addPOString("msgstr", msgstr))
+		keywords = append(keywords, addPOString("msgstr", msgstr))
 	}
 
+	if msg.Obsolete {
+		lines := strings.Split(strings.Join(keywords, "\n"), "\n")
+		for k := range lines {
+			lines[k] = "#~ " + lines[k]
+		}
+		keywords = lines
+	}
+	response = append(response, keywords...)
+
 	return strings.Join(response, "\n")
 }
 
@@ -83,7 +114,10 @@ func addPOString(key, value string) string {
 	}
 }
 
-func Compile(msgs []spec.Msg, target, name, pf string) string {
+// Compile writes msgs out as a catalog for target, named name and with the
+// Plural-Forms pf. header, if not nil, is written in place of a freshly
+// generated header, as when updating an existing catalog (see Merge).
+func Compile(header *spec.Msg, msgs []spec.Msg, target, name, pf string) string {
 	cdate, rdate := time.Now().Local().String(), "YEAR-MO-DA HO:MI +ZONE"
 	ltrans, lteam := "FULL NAME <EMAIL@ADDRESS>", "TEAM NAME <EMAIL@ADDRESS>"
 	mimever, cttype, ctenc := "1.0", "text/plain; charset=UTF-8", "8bit"
@@ -99,7 +133,7 @@ func Compile(msgs []spec.Msg, target, name, pf string) string {
 	}
 	comments += strings.Join(tmp, "\n") + "\n"
 
-	header := (`` + comments +
+	fresh := (`` + comments +
 		`msgid ""` + "\n" +
 		`msgstr ""` + "\n" +
 		`"Project-Id-Version: ` + o.General.ProjectName + `\n"` + "\n" +
@@ -116,7 +150,11 @@ func Compile(msgs []spec.Msg, target, name, pf string) string {
 		``)
 
 	var response []string
-	response = append(response, header)
+	if header != nil {
+		response = append(response, drawBlock(*header, target))
+	} else {
+		response = append(response, fresh)
+	}
 
 	for _, v := range msgs {
 		response = append(response, drawBlock(v, target))
@@ -0,0 +1,72 @@
+package po
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// Diagnostic is a problem the scanners ran into. Errors are files or parts
+// of files that could not be scanned at all; warnings are calls that could
+// not be extracted.
+type Diagnostic struct {
+	Pos token.Position // Line and Column are 0 if unknown
+	Msg string
+	Err bool
+}
+
+func (d Diagnostic) String() string {
+	return d.Pos.String() + ": " + d.Msg
+}
+
+// Report is called with each problem the scanners run into, one at a time,
+// after which scanning carries on. By default problems are printed to
+// standard error.
+var Report = func(d Diagnostic) {
+	fmt.Fprintln(os.Stderr, d)
+}
+
+// diagnostics collects the problems found scanning a file or directory,
+// to be reported once it is done, in the order files are walked
+type diagnostics []Diagnostic
+
+// report passes the problems collected on to Report
+func (ds diagnostics) report() {
+	for _, d := range ds {
+		Report(d)
+	}
+}
+
+func (ds *diagnostics) warnf(pos token.Position, format string, a ...interface{}) {
+	*ds = append(*ds, Diagnostic{Pos: pos, Msg: fmt.Sprintf(format, a...)})
+}
+
+// err adds an error reading or parsing a file
+func (ds *diagnostics) err(fn string, err error) {
+	switch e := err.(type) {
+	case scanner.ErrorList:
+		for _, v := range e {
+			*ds = append(*ds, Diagnostic{Pos: v.Pos, Msg: v.Msg, Err: true})
+		}
+	case *scanner.Error:
+		*ds = append(*ds, Diagnostic{Pos: e.Pos, Msg: e.Msg, Err: true})
+	default:
+		*ds = append(*ds, Diagnostic{Pos: token.Position{Filename: fn}, Msg: err.Error(), Err: true})
+	}
+}
+
+// tmplErrRE matches the errors of the template parser, which give the line
+var tmplErrRE = regexp.MustCompile(`^template: [^:]*:(\d+): (.*)$`)
+
+// tmplErr adds an error parsing the template in fn
+func (ds *diagnostics) tmplErr(fn string, err error) {
+	d := Diagnostic{Pos: token.Position{Filename: fn}, Msg: err.Error(), Err: true}
+	if m := tmplErrRE.FindStringSubmatch(d.Msg); m != nil {
+		d.Pos.Line, _ = strconv.Atoi(m[1])
+		d.Msg = m[2]
+	}
+	*ds = append(*ds, d)
+}
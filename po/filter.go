@@ -0,0 +1,213 @@
+package po
+
+import (
+	"bufio"
+	"fmt"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFN names the file, in the project directory, listing paths the
+// scanners should leave alone, one pattern per line as in .gitignore
+var IgnoreFN = ".pogoignore"
+
+// defaultExclude is what is left out when the configuration doesn't say
+var defaultExclude = []string{"vendor/", "node_modules/", "testdata/", ".*/"}
+
+// pattern is a path pattern of the include or exclude lists or of the
+// ignore file: a glob matched against the name of a file or directory at
+// any depth or, if it holds a slash, against its whole path relative to
+// the project directory, in which "**" stands for any number of
+// directories. A trailing slash restricts it to directories.
+type pattern struct {
+	glob     string
+	anchored bool
+	dirOnly  bool
+}
+
+func newPattern(s string) (p pattern, err error) {
+	if strings.HasSuffix(s, "/") {
+		p.dirOnly, s = true, strings.TrimRight(s, "/")
+	}
+	p.anchored = strings.Contains(s, "/")
+	p.glob = strings.TrimPrefix(s, "/")
+	if p.glob == "" {
+		return p, fmt.Errorf("empty pattern")
+	}
+	if _, err := path.Match(p.glob, ""); err != nil {
+		return p, fmt.Errorf("bad pattern %q", s)
+	}
+	return p, nil
+}
+
+// match reports whether the pattern matches rel, a slash-separated path
+// relative to the project directory
+func (p pattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if !p.anchored {
+		ok, _ := path.Match(p.glob, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(p.glob, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(glob, name []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			for k := 0; k <= len(name); k++ {
+				if matchSegments(glob[1:], name[k:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], name[0]); !ok {
+			return false
+		}
+		glob, name = glob[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// filter decides which files and directories under a project the scanners
+// look at
+type filter struct {
+	root          string
+	include       []pattern
+	exclude       []pattern
+	skipTests     bool
+	skipGenerated bool
+}
+
+// newFilter sets up the filter for the project at root from the [parsing]
+// configuration and the ignore file, reporting any bad patterns
+func newFilter(root string) *filter {
+	f := &filter{
+		root:          root,
+		skipTests:     o.Parsing.SkipTest,
+		skipGenerated: o.Parsing.SkipGen,
+	}
+	exclude := o.Parsing.Exclude
+	if exclude == nil {
+		exclude = defaultExclude
+	}
+	var ds diagnostics
+	f.include = compilePatterns(o.Parsing.Include, "include", &ds)
+	f.exclude = compilePatterns(exclude, "exclude", &ds)
+	f.exclude = append(f.exclude, readIgnore(filepath.Join(root, IgnoreFN), &ds)...)
+	ds.report()
+	return f
+}
+
+func compilePatterns(list []string, key string, ds *diagnostics) (res []pattern) {
+	for _, s := range list {
+		p, err := newPattern(s)
+		if err != nil {
+			*ds = append(*ds, Diagnostic{Pos: token.Position{Filename: cfgFN}, Msg: key + ": " + err.Error(), Err: true})
+			continue
+		}
+		res = append(res, p)
+	}
+	return
+}
+
+// readIgnore reads the patterns of an ignore file, if there is one. Blank
+// lines and lines starting with # are skipped.
+func readIgnore(fn string, ds *diagnostics) (res []pattern) {
+	file, err := os.Open(fn)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			ds.err(fn, err)
+		}
+		return nil
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		p, err := newPattern(s)
+		if err != nil {
+			*ds = append(*ds, Diagnostic{Pos: token.Position{Filename: fn, Line: line}, Msg: err.Error(), Err: true})
+			continue
+		}
+		res = append(res, p)
+	}
+	return
+}
+
+// rel returns the slash-separated path of fp relative to the project
+func (f *filter) rel(fp string) string {
+	rel, err := filepath.Rel(f.root, fp)
+	if err != nil {
+		return filepath.ToSlash(fp)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (f *filter) excluded(rel string, isDir bool) bool {
+	for _, p := range f.exclude {
+		if p.match(rel, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipDir reports whether to leave out a directory and everything in it:
+// one that is excluded, or that holds a project of its own
+func (f *filter) skipDir(fp string) bool {
+	if fp == f.root {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(fp, cfgFN)); err == nil {
+		return true
+	}
+	return f.excluded(f.rel(fp), true)
+}
+
+// skipFile reports whether to leave out a file, judging by its path
+func (f *filter) skipFile(fp string) bool {
+	if f.skipTests && strings.HasSuffix(fp, "_test.go") {
+		return true
+	}
+	rel := f.rel(fp)
+	if f.excluded(rel, false) {
+		return true
+	}
+	if len(f.include) == 0 {
+		return false
+	}
+	for _, p := range f.include {
+		if p.match(rel, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// generatedTmpl matches the first line of a generated template, as the Go
+// convention has it: "Code generated ... DO NOT EDIT.", within any comment
+var generatedTmpl = regexp.MustCompile(`Code generated .* DO NOT EDIT\.`)
+
+// generatedTmplSrc reports whether a template is marked as generated on its
+// first non-blank line
+func generatedTmplSrc(src string) bool {
+	for _, line := range strings.Split(src, "\n") {
+		if strings.TrimSpace(line) != "" {
+			return generatedTmpl.MatchString(line)
+		}
+	}
+	return false
+}
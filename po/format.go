@@ -0,0 +1,57 @@
+package po
+
+import (
+	"github.com/Sam-Izdat/pogo/catalog"
+	spec "github.com/Sam-Izdat/pogo/gtspec"
+	"regexp"
+	"strings"
+)
+
+// goVerb matches a fmt verb at the start of a string: flags, an argument
+// index, width and precision, each optional, and the verb. The space flag
+// is left out, so that "50% off" isn't taken for "% o".
+var goVerb = regexp.MustCompile(`^%[+\-#0]*(\[\d+\])?(\d+|\*|\[\d+\]\*)?(\.(\d+|\*|\[\d+\]\*)?)?(\[\d+\])?[bcdeEfFgGoOpqstTUvwxX]`)
+
+// isGoFormat reports whether a string holds any fmt verbs, such as "%s",
+// "%5.2f" or "%[2]d", other than "%%"
+func isGoFormat(s string) bool {
+	for i := strings.IndexByte(s, '%'); i >= 0; i = strings.IndexByte(s, '%') {
+		s = s[i:]
+		if strings.HasPrefix(s, "%%") {
+			s = s[2:]
+			continue
+		}
+		if goVerb.MatchString(s) {
+			return true
+		}
+		s = s[1:]
+	}
+	return false
+}
+
+// flagFormat flags a message whose msgid or msgid_plural is a format
+// string, as such, unless its source comments say whether it is one
+func flagFormat(msg *spec.Msg) {
+	if catalog.HasFlag(*msg, formatFlag) || catalog.HasFlag(*msg, "no-"+formatFlag) {
+		return
+	}
+	if isGoFormat(msg.Id) || isGoFormat(msg.IdPlural) {
+		addFlag(msg, formatFlag)
+	}
+}
+
+// commentFlags returns the flags a source comment sets, as in GNU
+// xgettext: "xgettext:" followed by flags such as "no-go-format", e.g.
+// "// xgettext:no-go-format" before a call
+func commentFlags(text string) (flags []string) {
+	for _, line := range strings.Split(text, "\n") {
+		i := strings.Index(line, "xgettext:")
+		if i < 0 {
+			continue
+		}
+		flags = append(flags, strings.FieldsFunc(line[i+len("xgettext:"):], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})...)
+	}
+	return
+}
@@ -0,0 +1,252 @@
+package po
+
+import (
+	spec "github.com/Sam-Izdat/pogo/gtspec"
+	"go/ast"
+	"go/build"
+	"go/constant"
+	prsGo "go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// shallowImporter imports packages from source without following their own
+// imports, which are stood in for by empty packages, as are the standard
+// library and anything that can't be found. That is enough to resolve
+// constants and named types without type-checking every dependency. It is
+// safe for concurrent use.
+type shallowImporter struct {
+	mu    sync.Mutex
+	fset  *token.FileSet
+	pkgs  map[string]*types.Package
+	files map[string]map[string]string // package path -> file -> content hash
+}
+
+func newImporter() *shallowImporter {
+	return &shallowImporter{
+		fset:  token.NewFileSet(),
+		pkgs:  make(map[string]*types.Package),
+		files: make(map[string]map[string]string),
+	}
+}
+
+func (imp *shallowImporter) Import(path string) (*types.Package, error) {
+	return imp.ImportFrom(path, "", 0)
+}
+
+func (imp *shallowImporter) ImportFrom(p, dir string, mode types.ImportMode) (*types.Package, error) {
+	if p == "unsafe" {
+		return types.Unsafe, nil
+	}
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	bp, err := build.Import(p, dir, 0)
+	if err == nil && !bp.Goroot {
+		p = bp.ImportPath
+	}
+	if pkg, ok := imp.pkgs[p]; ok {
+		return pkg, nil
+	}
+	var files []*ast.File
+	sums := make(map[string]string)
+	if err == nil && !bp.Goroot {
+		for _, fn := range bp.GoFiles {
+			fn = filepath.Join(bp.Dir, fn)
+			src, err := os.ReadFile(fn)
+			if err != nil {
+				continue
+			}
+			sums[fn] = hashBytes(src)
+			if f, err := prsGo.ParseFile(imp.fset, fn, src, 0); err == nil {
+				files = append(files, f)
+			}
+		}
+	}
+	var pkg *types.Package
+	if len(files) > 0 {
+		conf := types.Config{Importer: stubImporter{}, Error: func(error) {}}
+		pkg, _ = conf.Check(p, imp.fset, files, nil)
+	} else {
+		pkg = stubImporter{}.stub(p)
+	}
+	imp.pkgs[p] = pkg
+	imp.files[p] = sums
+	return pkg, nil
+}
+
+// recordingImporter imports packages for type-checking one directory, and
+// notes the files they were imported from, for the cache
+type recordingImporter struct {
+	imp   *shallowImporter
+	files map[string]string // file -> content hash
+}
+
+func (r recordingImporter) Import(path string) (*types.Package, error) {
+	return r.ImportFrom(path, "", 0)
+}
+
+func (r recordingImporter) ImportFrom(p, dir string, mode types.ImportMode) (*types.Package, error) {
+	pkg, err := r.imp.ImportFrom(p, dir, mode)
+	if err == nil {
+		r.imp.mu.Lock()
+		for fn, sum := range r.imp.files[pkg.Path()] {
+			r.files[fn] = sum
+		}
+		r.imp.mu.Unlock()
+	}
+	return pkg, err
+}
+
+// stubImporter stands in an empty package for every import
+type stubImporter struct{}
+
+func (stubImporter) Import(p string) (*types.Package, error) {
+	if p == "unsafe" {
+		return types.Unsafe, nil
+	}
+	return stubImporter{}.stub(p), nil
+}
+
+func (stubImporter) stub(p string) *types.Package {
+	pkg := types.NewPackage(p, path.Base(p))
+	pkg.MarkComplete()
+	return pkg
+}
+
+// importPath returns the import path of the package in dir, or name if
+// there is no telling
+func importPath(dir, name string) string {
+	p, ok := spec.ImportPath(dir)
+	if !ok {
+		return name
+	}
+	if strings.HasSuffix(name, "_test") {
+		return p + "_test"
+	}
+	return p
+}
+
+// fileNames returns the names of the files of a parsed package in order
+func fileNames(pkg *ast.Package) []string {
+	names := make([]string, 0, len(pkg.Files))
+	for fn := range pkg.Files {
+		names = append(names, fn)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// typeCheck type-checks a parsed package, ignoring errors, and returns what
+// could be learned about its expressions
+func typeCheck(fset *token.FileSet, pkg *ast.Package, path string, imp types.Importer) *types.Info {
+	names := fileNames(pkg)
+	files := make([]*ast.File, len(names))
+	for k, fn := range names {
+		files[k] = pkg.Files[fn]
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	conf := types.Config{Importer: imp, Error: func(error) {}}
+	conf.Check(path, fset, files, info)
+	return info
+}
+
+// callee returns the function, method or variable a call is made through,
+// or nil if type checking couldn't tell
+func callee(info *types.Info, fun ast.Expr) types.Object {
+	if info == nil {
+		return nil
+	}
+	switch f := ast.Unparen(fun).(type) {
+	case *ast.Ident:
+		return info.Uses[f]
+	case *ast.SelectorExpr:
+		if sel, ok := info.Selections[f]; ok {
+			return sel.Obj()
+		}
+		return info.Uses[f.Sel] // qualified identifier
+	}
+	return nil
+}
+
+// objName names a package-level function or variable by its package path
+// and name, e.g. "example.com/app/i18n.Tr", and a method by its type's as
+// well, e.g. "example.com/app/i18n.Locale.Tr"
+func objName(obj types.Object) string {
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	prefix := obj.Pkg().Path()
+	if i := strings.LastIndex(prefix, "/vendor/"); i >= 0 {
+		prefix = prefix[i+len("/vendor/"):]
+	}
+	if fn, ok := obj.(*types.Func); ok {
+		if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+			t := recv.Type()
+			if p, ok := t.(*types.Pointer); ok {
+				t = p.Elem()
+			}
+			if named, ok := types.Unalias(t).(*types.Named); ok {
+				prefix += "." + named.Obj().Name()
+			}
+		}
+	}
+	return prefix + "." + obj.Name()
+}
+
+// stringArg returns the quoted string an argument evaluates to, if it is a
+// constant: either a literal, as written, or a constant expression such as
+// "a" + "b" or the name of a string constant, folded into a `raw` string.
+func stringArg(info *types.Info, arg ast.Expr) (string, bool) {
+	if lit, ok := arg.(*ast.BasicLit); ok {
+		return lit.Value, lit.Kind == token.STRING
+	}
+	if info != nil {
+		if tv, ok := info.Types[arg]; ok && tv.Value != nil {
+			if tv.Value.Kind() != constant.String {
+				return "", false
+			}
+			return "`" + constant.StringVal(tv.Value) + "`", true
+		}
+	}
+	if s, ok := foldString(arg); ok {
+		return "`" + s + "`", true
+	}
+	return "", false
+}
+
+// foldString concatenates string literals joined by +, for when type
+// checking has nothing to say about an expression
+func foldString(x ast.Expr) (string, bool) {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		if x.Kind != token.STRING {
+			return "", false
+		}
+		s, err := strconv.Unquote(x.Value)
+		return s, err == nil
+	case *ast.ParenExpr:
+		return foldString(x.X)
+	case *ast.BinaryExpr:
+		if x.Op != token.ADD {
+			return "", false
+		}
+		a, ok := foldString(x.X)
+		if !ok {
+			return "", false
+		}
+		b, ok := foldString(x.Y)
+		return a + b, ok
+	}
+	return "", false
+}
@@ -0,0 +1,274 @@
+package po
+
+import (
+	"github.com/Sam-Izdat/pogo/catalog"
+	spec "github.com/Sam-Izdat/pogo/gtspec"
+	"strings"
+	"time"
+)
+
+// MergeStats tallies what Merge did to a catalog
+type MergeStats struct {
+	Kept, Fuzzy, Added, Obsolete int
+}
+
+// FuzzyThreshold is the similarity (0 to 1) above which Merge considers a
+// vanished message to be an earlier version of a new one
+var FuzzyThreshold = 0.6
+
+// Merge updates an existing catalog def (as read by Parse) with the
+// messages in ref (as extracted by the scanners), much like msgmerge:
+// translations are kept for messages still found in ref, new messages are
+// added untranslated, vanished ones are commented out as obsolete ("#~")
+// entries and references are refreshed from ref. New messages that closely
+// resemble a vanished one take over its translation, marked fuzzy and
+// annotated with the previous msgid ("#|"). The header of def, if any, is
+// returned apart from the messages, with an updated POT-Creation-Date, to be
+// passed on to Compile.
+func Merge(def, ref []spec.Msg) (res []spec.Msg, header *spec.Msg, stats MergeStats) {
+	index := make(map[string]int)
+	for k, v := range def {
+		if v.Id == "" && v.Ctxt == "" {
+			if header == nil {
+				h := refreshHeader(v)
+				header = &h
+			}
+			continue
+		}
+		key := v.Ctxt + "\x04" + v.Id
+		if j, ok := index[key]; ok && !def[j].Obsolete {
+			continue // prefer live entries over obsolete duplicates
+		}
+		index[key] = k
+	}
+
+	// exact matches go first, so that none of them is taken by a fuzzy one
+	used := make([]bool, len(def))
+	matched := make([]bool, len(ref))
+	for i, v := range ref {
+		msg := v
+		msg.Comments = make(spec.CommentPack)
+		for _, key := range []string{"reference", "extracted", "flag"} {
+			if len(v.Comments[key]) > 0 {
+				msg.Comments[key] = v.Comments[key]
+			}
+		}
+		if k, ok := index[msg.Ctxt+"\x04"+msg.Id]; ok && !used[k] {
+			used[k], matched[i] = true, true
+			carryTranslation(&msg, def[k])
+			stats.Kept++
+		}
+		res = append(res, msg)
+	}
+	for i := range ref {
+		if matched[i] {
+			continue
+		}
+		msg := &res[i]
+		if k := fuzzyMatch(*msg, def, used); k >= 0 {
+			used[k] = true
+			carryFuzzy(msg, def[k])
+			stats.Fuzzy++
+		} else {
+			stats.Added++
+		}
+	}
+
+	for k, v := range def {
+		if used[k] || (v.Id == "" && v.Ctxt == "") {
+			continue
+		}
+		if !v.Obsolete {
+			stats.Obsolete++
+		}
+		v.Obsolete = true
+		delete(v.Comments, "reference")
+		res = append(res, v)
+	}
+	return
+}
+
+// carryTranslation copies the translation and the translators' comments
+// and flags of old over to msg. Flags that the source determines, such
+// as "go-format", are left as scanned. If the plural form of the msgid
+// changed in the meantime, the translation is adapted and marked fuzzy.
+func carryTranslation(msg *spec.Msg, old spec.Msg) {
+	for _, key := range []string{"translator", "previous"} {
+		if len(old.Comments[key]) > 0 {
+			msg.Comments[key] = old.Comments[key]
+		}
+	}
+	var flags []string
+	for _, line := range old.Comments["flag"] {
+		for _, f := range strings.Split(line, ",") {
+			if f = strings.TrimSpace(f); f != "" && !isSourceFlag(f) {
+				flags = append(flags, f)
+			}
+		}
+	}
+	for i := len(flags) - 1; i >= 0; i-- { // addFlag prepends
+		addFlag(msg, flags[i])
+	}
+	if !catalog.HasFlag(old, "fuzzy") {
+		delete(msg.Comments, "previous") // translator has reviewed it since
+	}
+	msg.Str, msg.StrPlural = old.Str, old.StrPlural
+	switch {
+	case msg.IdPlural == old.IdPlural:
+		return
+	case msg.IdPlural == "":
+		if len(old.StrPlural) > 0 {
+			msg.Str = old.StrPlural[0]
+		}
+		msg.StrPlural = nil
+	case old.IdPlural == "":
+		msg.Str, msg.StrPlural = "", []string{old.Str}
+	}
+	addFlag(msg, "fuzzy")
+}
+
+// carryFuzzy carries the translation of old over to msg, a message that
+// is similar but not identical, leaving a trail of the previous strings
+func carryFuzzy(msg *spec.Msg, old spec.Msg) {
+	carryTranslation(msg, old)
+	addFlag(msg, "fuzzy")
+	var previous []string
+	if old.Ctxt != "" {
+		previous = append(previous, `msgctxt "`+old.Ctxt+`"`)
+	}
+	previous = append(previous, `msgid "`+old.Id+`"`)
+	if old.IdPlural != "" {
+		previous = append(previous, `msgid_plural "`+old.IdPlural+`"`)
+	}
+	msg.Comments["previous"] = previous
+}
+
+// fuzzyMatch returns the index of the unused, translated message in def
+// that most resembles msg, or -1 if none is similar enough
+func fuzzyMatch(msg spec.Msg, def []spec.Msg, used []bool) int {
+	best, bestScore := -1, FuzzyThreshold
+	key := []rune(msg.Ctxt + "\x04" + msg.Id)
+	for k, v := range def {
+		if used[k] || (v.Id == "" && v.Ctxt == "") || !hasTranslation(v) {
+			continue
+		}
+		if score := similarity(key, []rune(v.Ctxt+"\x04"+v.Id), bestScore); score > bestScore {
+			best, bestScore = k, score
+		}
+	}
+	return best
+}
+
+// hasTranslation reports whether any msgstr of a message is filled in
+func hasTranslation(msg spec.Msg) bool {
+	if msg.Str != "" {
+		return true
+	}
+	for _, v := range msg.StrPlural {
+		if v != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// similarity rates two strings from 0 (nothing in common) to 1 (equal) by
+// their edit distance. Pairs that cannot beat floor are rejected early, and
+// rated 0.
+func similarity(a, b []rune, floor float64) float64 {
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	if longest == 0 {
+		return 1
+	}
+	maxDist := int((1 - floor) * float64(longest))
+	diff := len(a) - len(b)
+	if diff > maxDist || -diff > maxDist {
+		return 0
+	}
+	dist := levenshtein(a, b)
+	return 1 - float64(dist)/float64(longest)
+}
+
+// levenshtein computes the edit distance between two strings
+func levenshtein(a, b []rune) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(a int, b ...int) int {
+	for _, v := range b {
+		if v < a {
+			a = v
+		}
+	}
+	return a
+}
+
+// isSourceFlag tells whether a flag is set by the scanner, according to
+// the source, rather than by translators or tools
+func isSourceFlag(flag string) bool {
+	return strings.HasSuffix(flag, "-format")
+}
+
+// addFlag adds a "#," flag to a message unless it is already present
+func addFlag(msg *spec.Msg, flag string) {
+	if catalog.HasFlag(*msg, flag) {
+		return
+	}
+	flags := msg.Comments["flag"]
+	if len(flags) == 0 {
+		msg.Comments["flag"] = []string{flag}
+		return
+	}
+	msg.Comments["flag"] = append([]string{flag + ", " + flags[0]}, flags[1:]...)
+}
+
+// removeFlag takes a "#," flag off a message
+func removeFlag(msg *spec.Msg, flag string) {
+	var lines []string
+	for _, line := range msg.Comments["flag"] {
+		var kept []string
+		for _, f := range strings.Split(line, ",") {
+			if f = strings.TrimSpace(f); f != flag {
+				kept = append(kept, f)
+			}
+		}
+		if len(kept) > 0 {
+			lines = append(lines, strings.Join(kept, ", "))
+		}
+	}
+	if lines == nil {
+		delete(msg.Comments, "flag")
+		return
+	}
+	msg.Comments["flag"] = lines
+}
+
+// refreshHeader stamps a catalog header with the current creation date
+func refreshHeader(msg spec.Msg) spec.Msg {
+	fields := strings.Split(msg.Str, `\n`)
+	for k, v := range fields {
+		if strings.HasPrefix(v, "POT-Creation-Date:") {
+			fields[k] = "POT-Creation-Date: " + time.Now().Local().String()
+		}
+	}
+	msg.Str = strings.Join(fields, `\n`)
+	return msg
+}
@@ -1,20 +1,30 @@
 package po
 
 import (
-	prsTmpl "github.com/Sam-Izdat/pogo/deps/template/parse"
+	"bytes"
+	"fmt"
 	spec "github.com/Sam-Izdat/pogo/gtspec"
 	"go/ast"
 	prsGo "go/parser"
 	"go/token"
-	"io/ioutil"
+	"go/types"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	prsTmpl "text/template/parse"
 )
 
-var gf, ngf, pgf, npgf, lDelim, rDelim string
+var gf, ngf, pgf, npgf, ogf, pogf, lDelim, rDelim, tag, formatFlag string
 var cfgFN string
+var typed bool
+var typedFuncs map[string]string // objName -> "G", "NG", etc.
+
+// translatorType is the type whose methods are extracted when type checking
+const translatorType = "github.com/Sam-Izdat/pogo/translate.Translator"
 
 func init() {
 	loadOptions()
@@ -23,283 +33,655 @@ func init() {
 	ngf = o.Parsing.FuncNG   // "ngettext" function
 	pgf = o.Parsing.FuncPG   // "pgettext" function
 	npgf = o.Parsing.FuncNPG // "npgettext" function
+	ogf = o.Parsing.FuncOG   // ordinal "gettext" function
+	pogf = o.Parsing.FuncPOG // ordinal "pgettext" function
+	// configurations predating ordinals don't name their functions
+	if ogf == "" {
+		ogf = "OG"
+	}
+	if pogf == "" {
+		pogf = "POG"
+	}
 	lDelim = o.Parsing.DelimL
 	rDelim = o.Parsing.DelimR
+	tag = o.Parsing.Tag
+	if tag == "" {
+		tag = "TRANSLATORS:"
+	}
+	formatFlag = o.Parsing.FmtFlag
+	if formatFlag == "" {
+		formatFlag = "go-format"
+	}
+	typed = o.Parsing.Typed
+	typedFuncs = make(map[string]string)
+	for _, kind := range []string{"G", "NG", "PG", "NPG", "OG", "POG"} {
+		typedFuncs[translatorType+"."+kind] = kind
+	}
+	for name, kind := range o.Parsing.Wrappers {
+		if s, err := strconv.Unquote(name); err == nil {
+			name = s
+		}
+		typedFuncs[name] = kind
+	}
 }
 
 func ScanGo(path string) (res []spec.Msg) {
+	filter := newFilter(path)
+	var dirs []string
+	var ds diagnostics
 	filepath.Walk(path, func(fp string, fi os.FileInfo, err error) error {
 		if err != nil {
+			ds.err(fp, err)
 			return nil
 		}
 		if !!fi.IsDir() {
-			switch fi.Name() {
-			case ".", "..":
-				return nil
-			default:
-				tmp := scanGoDir(fp)
-				if tmp != nil {
-					res = append(res, tmp...)
-				}
+			if filter.skipDir(fp) {
+				return filepath.SkipDir
 			}
+			dirs = append(dirs, fp)
 		}
 		return nil
 	})
+	ds.report()
+
+	imp := newImporter()
+	c := openCache(path, "go")
+	found := scanAll(len(dirs), func(k int) *cacheEntry {
+		return scanGoDir(dirs[k], imp, filter, c)
+	})
+	for k, e := range found {
+		e.Diags.report()
+		res = append(res, e.Msgs...)
+		if len(e.Files) > 0 {
+			c.store(dirs[k], e)
+		}
+	}
+	c.save()
 	prepMsg(&res)
 	return
 }
 
-func scanGoDir(path string) (res []spec.Msg) {
-	// skip any subdirectory with its own POGO.toml config
-	conf := filepath.Join(path, cfgFN)
-	if _, err := os.Stat(conf); err == nil && path != o.General.DirProject {
-		return nil
+// scanAll scans n directories or files, scan(k) being the kth, on as many
+// goroutines as there are CPUs, and returns what was found in order
+func scanAll(n int, scan func(k int) *cacheEntry) []*cacheEntry {
+	found := make([]*cacheEntry, n)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range next {
+				found[k] = scan(k)
+			}
+		}()
+	}
+	for k := 0; k < n; k++ {
+		next <- k
+	}
+	close(next)
+	wg.Wait()
+	return found
+}
+
+// callArgs names what the leading arguments of a call of kind hold, in
+// Go or in a template (after the function)
+func callArgs(kind string) []string {
+	switch kind {
+	case "G", "OG":
+		return []string{"text"}
+	case "NG":
+		return []string{"singular", "plural"}
+	case "PG", "POG":
+		return []string{"context", "text"}
+	case "NPG":
+		return []string{"context", "singular", "plural"}
+	}
+	return nil
+}
+
+// callKind tells which of the translation functions - "G", "NG", "PG",
+// "NPG", "OG" or "POG" - a call made by name is to, if any. When type
+// checking, that is whichever the function called is or wraps, if type
+// checking can tell which function it is, and otherwise goes by name.
+func callKind(info *types.Info, x *ast.CallExpr, name string) string {
+	if typed {
+		if obj := callee(info, x.Fun); obj != nil {
+			return typedFuncs[objName(obj)]
+		}
+	}
+	return nameKind(name)
+}
+
+// nameKind tells which of the translation functions a function or method
+// is by its name, as configured
+func nameKind(name string) string {
+	if name == "" {
+		return ""
+	}
+	switch name {
+	case gf:
+		return "G"
+	case ngf:
+		return "NG"
+	case pgf:
+		return "PG"
+	case npgf:
+		return "NPG"
+	case ogf:
+		return "OG"
+	case pogf:
+		return "POG"
+	}
+	return ""
+}
+
+// scanGoDir scans the .go files of a directory, unless neither they nor the
+// packages they import have changed since they were cached
+func scanGoDir(path string, imp *shallowImporter, filter *filter, c *cache) *cacheEntry {
+	e := &cacheEntry{Files: make(map[string]string)}
+	names, srcs := readGoDir(path, filter, &e.Diags)
+	for _, fn := range names {
+		e.Files[fn] = hashBytes(srcs[fn])
+	}
+	if cached := c.lookup(path, e.Files); cached != nil {
+		return cached
 	}
 
 	fset := token.NewFileSet()
-	pkgs, err := prsGo.ParseDir(fset, path, nil, 0)
-	if err != nil {
-		panic(err)
-	}
-	for _, pkg := range pkgs {
-		for fn, f := range pkg.Files {
-			ast.Inspect(f, func(n ast.Node) bool {
-				var funcName string
-				switch x := n.(type) {
-				case *ast.CallExpr:
-					switch y := x.Fun.(type) {
-					case *ast.Ident: // function call
-						funcName = y.Name
-					case *ast.SelectorExpr: // method call
-						funcName = y.Sel.Name
-					}
-					switch funcName {
-					case gf, ngf, pgf, npgf: // do nothing
-					default:
-						return true
-					}
-					msgs := []spec.Msg{}
-					for k, arg := range x.Args {
-						switch y := arg.(type) {
-						case *ast.BasicLit:
-							linePos := fset.Position(y.ValuePos).Line
-							switch funcName {
-							case gf: // just singular
-								msgs = append(msgs, spec.Msg{Filename: fn, Line: linePos, Id: y.Value})
-							case ngf: // singular and plural
-								switch k { // 0 - singular, 1 - plural, subsequent ignored
-								case 0:
-									msgs = append(msgs, spec.Msg{Filename: fn, Line: linePos, Id: y.Value})
-								case 1:
-									msgs[0].IdPlural = y.Value
-								}
-							case pgf: // context and text
-								switch k { // 0 - context, 1 - text, subsequent ignored
-								case 0:
-									msgs = append(msgs, spec.Msg{Filename: fn, Line: linePos, Ctxt: y.Value})
-								case 1:
-									msgs[0].Id = y.Value
-								}
-							case npgf:
-								switch k { // 0 - context, 1 - singular, 2 - plural, subsequent ignored
-								case 0:
-									msgs = append(msgs, spec.Msg{Filename: fn, Line: linePos, Ctxt: y.Value})
-								case 1:
-									msgs[0].Id = y.Value
-								case 2:
-									msgs[0].IdPlural = y.Value
-								}
-							}
-						}
-					}
-					if len(msgs) > 0 {
-						res = append(res, msgs...)
+	pkgs := parseGoDir(fset, names, srcs, filter, &e.Diags)
+	pkgNames := make([]string, 0, len(pkgs))
+	for name := range pkgs {
+		pkgNames = append(pkgNames, name)
+	}
+	sort.Strings(pkgNames)
+	for _, name := range pkgNames {
+		pkg := pkgs[name]
+		info := typeCheck(fset, pkg, importPath(path, pkg.Name), recordingImporter{imp, e.Files})
+		e.Msgs = append(e.Msgs, scanGoPkg(fset, pkg, info, &e.Diags)...)
+	}
+	return e
+}
+
+// scanGoPkg extracts the messages of a parsed package
+func scanGoPkg(fset *token.FileSet, pkg *ast.Package, info *types.Info, ds *diagnostics) (res []spec.Msg) {
+	for _, fn := range fileNames(pkg) {
+		f := pkg.Files[fn]
+		notes := goNotes(fset, f)
+		ast.Inspect(f, func(n ast.Node) bool {
+			var funcName string
+			x, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			switch y := x.Fun.(type) {
+			case *ast.Ident: // function call
+				funcName = y.Name
+			case *ast.SelectorExpr: // method call
+				funcName = y.Sel.Name
+			}
+			kind := callKind(info, x, funcName)
+			args := callArgs(kind)
+			if args == nil {
+				return true
+			}
+			if len(x.Args) < len(args) {
+				ds.warnf(fset.Position(x.Pos()), "%s called with too few arguments; skipped", funcName)
+				return true
+			}
+			msg := spec.Msg{Filename: fn, Line: fset.Position(x.Args[0].Pos()).Line}
+			for k, arg := range args {
+				s, ok := stringArg(info, x.Args[k])
+				if !ok {
+					ds.warnf(fset.Position(x.Args[k].Pos()),
+						"%s argument to %s is not a constant string; skipped", arg, funcName)
+					return true
+				}
+				switch arg {
+				case "context":
+					msg.Ctxt = s
+				case "text", "singular":
+					msg.Id = s
+				case "plural":
+					msg.IdPlural = s
+				}
+			}
+			msg = withNote(msg, goNote(fset, notes, x))
+			switch kind {
+			case "G": // remaining strings are translated too
+				res = append(res, msg)
+				for _, arg := range x.Args[1:] {
+					if s, ok := stringArg(info, arg); ok {
+						res = append(res, spec.Msg{Filename: fn, Line: fset.Position(arg.Pos()).Line, Id: s})
 					}
 				}
-				return true
-			})
-		}
+			case "OG", "POG": // a form per ordinal
+				res = append(res, ordinalMsg(msg))
+			default:
+				res = append(res, msg)
+			}
+			return true
+		})
 	}
 	return
 }
 
+// goNotes maps the line each comment for translators, or setting flags,
+// in f ends on to the comment
+func goNotes(fset *token.FileSet, f *ast.File) map[int]*ast.CommentGroup {
+	notes := make(map[int]*ast.CommentGroup)
+	for _, cg := range f.Comments {
+		if text := commentText(cg); note(text) != nil || commentFlags(text) != nil {
+			notes[fset.Position(cg.End()).Line] = cg
+		}
+	}
+	return notes
+}
+
+// goNote returns the text of the comment for translators, or setting
+// flags, ending just before a call, on the same line or the one before
+func goNote(fset *token.FileSet, notes map[int]*ast.CommentGroup, x *ast.CallExpr) string {
+	line := fset.Position(x.Pos()).Line
+	for _, l := range []int{line, line - 1} {
+		if cg, ok := notes[l]; ok && cg.End() <= x.Pos() {
+			return commentText(cg)
+		}
+	}
+	return ""
+}
+
+// commentText returns the text of a comment, without the comment markers.
+// Unlike ast.CommentGroup.Text, it keeps lines such as
+// "//xgettext:no-go-format" that look like directives to the compiler.
+func commentText(cg *ast.CommentGroup) string {
+	lines := make([]string, len(cg.List))
+	for k, c := range cg.List {
+		if strings.HasPrefix(c.Text, "//") {
+			lines[k] = c.Text[2:]
+		} else {
+			lines[k] = strings.TrimSuffix(c.Text[2:], "*/")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// readGoDir reads the .go files in a directory that the filter lets
+// through, and returns their names in order along with their contents
+func readGoDir(path string, filter *filter, ds *diagnostics) (names []string, srcs map[string][]byte) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		ds.err(path, err)
+		return nil, nil
+	}
+	srcs = make(map[string][]byte)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") {
+			continue
+		}
+		fn := filepath.Join(path, e.Name())
+		if filter.skipFile(fn) {
+			continue
+		}
+		src, err := os.ReadFile(fn)
+		if err != nil {
+			ds.err(fn, err)
+			continue
+		}
+		names = append(names, fn)
+		srcs[fn] = src
+	}
+	return names, srcs
+}
+
+// parseGoDir parses the .go files read from a directory, like
+// go/parser.ParseDir, but reports the files it cannot parse and carries on
+// with the others, and leaves out generated files if the filter does
+func parseGoDir(fset *token.FileSet, names []string, srcs map[string][]byte, filter *filter, ds *diagnostics) map[string]*ast.Package {
+	pkgs := make(map[string]*ast.Package)
+	for _, fn := range names {
+		f, err := prsGo.ParseFile(fset, fn, srcs[fn], prsGo.ParseComments)
+		if err != nil {
+			ds.err(fn, err)
+			continue
+		}
+		if filter.skipGenerated && ast.IsGenerated(f) {
+			continue
+		}
+		pkg, ok := pkgs[f.Name.Name]
+		if !ok {
+			pkg = &ast.Package{Name: f.Name.Name, Files: make(map[string]*ast.File)}
+			pkgs[f.Name.Name] = pkg
+		}
+		pkg.Files[fn] = f
+	}
+	return pkgs
+}
+
 func ScanTmpl(path string) (res []spec.Msg) {
+	var exts []string
+	var ds diagnostics
+	for _, ext := range o.Parsing.TmplExts {
+		if _, err := filepath.Match("*."+ext, ""); err != nil {
+			ds = append(ds, Diagnostic{Pos: token.Position{Filename: cfgFN},
+				Msg: fmt.Sprintf("extensions_template: bad pattern %q", ext), Err: true})
+			continue
+		}
+		exts = append(exts, ext)
+	}
+	filter := newFilter(path)
+	var files []string
 	filepath.Walk(path, func(fp string, fi os.FileInfo, err error) error {
 		if err != nil {
+			ds.err(fp, err)
 			return nil
 		}
-
-		// skip any subdirectory with its own POGO.toml config
-		dir := filepath.Base(fp)
-		conf := filepath.Join(dir, cfgFN)
-		if _, err := os.Stat(conf); err == nil && dir != o.General.DirProject {
+		if !!fi.IsDir() {
+			if filter.skipDir(fp) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
-
-		if !!fi.IsDir() {
+		if filter.skipFile(fp) {
 			return nil
 		}
-
-		matched := false
-		for _, ext := range o.Parsing.TmplExts {
-			var err error
-			matched, err = filepath.Match("*."+ext, fi.Name())
-			if err != nil {
-				panic(err)
-			}
-			if matched {
+		for _, ext := range exts {
+			if matched, _ := filepath.Match("*."+ext, fi.Name()); matched {
+				files = append(files, fp)
 				break
 			}
 		}
-
-		if matched {
-			buf, err := ioutil.ReadFile(fp)
-			if err != nil {
-				panic(err)
-			}
-			tmpl := string(buf)
-			scn := scanTmplString(tmpl)
-			for k := range scn {
-				scn[k].Filename = fp
-			}
-			res = append(res, scn...)
-		}
 		return nil
 	})
+	ds.report()
+
+	c := openCache(path, "tmpl")
+	found := scanAll(len(files), func(k int) *cacheEntry {
+		return scanTmplFile(files[k], filter, c)
+	})
+	for k, e := range found {
+		e.Diags.report()
+		res = append(res, e.Msgs...)
+		if len(e.Files) > 0 {
+			c.store(files[k], e)
+		}
+	}
+	c.save()
 	prepMsg(&res)
 	return
 }
 
-func scanTmplString(s string) (res []spec.Msg) {
-	t, err := prsTmpl.Parse("p", s, lDelim, rDelim, map[string]interface{}{})
+// scanTmplFile scans a template, unless it hasn't changed since it was
+// cached
+func scanTmplFile(fp string, filter *filter, c *cache) *cacheEntry {
+	e := &cacheEntry{}
+	buf, err := os.ReadFile(fp)
 	if err != nil {
-		panic(err)
+		e.Diags.err(fp, err)
+		return e
 	}
-	for _, va := range t {
-		res = append(res, scanNodes(va.List.Nodes)...)
+	e.Files = map[string]string{fp: hashBytes(buf)}
+	if cached := c.lookup(fp, e.Files); cached != nil {
+		return cached
 	}
-	return
+	tmpl := string(buf)
+	if filter.skipGenerated && generatedTmplSrc(tmpl) {
+		return e
+	}
+	scn, err := scanTmplString(tmpl)
+	if err != nil {
+		e.Diags.tmplErr(fp, err)
+		return e
+	}
+	for k := range scn {
+		scn[k].Filename = fp
+	}
+	e.Msgs = scn
+	return e
 }
 
+func scanTmplString(s string) (res []spec.Msg, err error) {
+	t := prsTmpl.New("p")
+	t.Mode = prsTmpl.ParseComments | prsTmpl.SkipFuncCheck
+	set := make(map[string]*prsTmpl.Tree) // the template and its definitions
+	if _, err := t.Parse(s, lDelim, rDelim, set); err != nil {
+		return nil, err
+	}
+	trees := make([]*prsTmpl.Tree, 0, len(set))
+	for _, tree := range set {
+		trees = append(trees, tree)
+	}
+	sort.Slice(trees, func(i, j int) bool {
+		if trees[i].Root.Pos != trees[j].Root.Pos {
+			return trees[i].Root.Pos < trees[j].Root.Pos
+		}
+		return trees[i].Name < trees[j].Name
+	})
+	for _, tree := range trees {
+		res = append(res, scanNode(tree.Root)...)
+	}
+	return res, nil
+}
+
+// scanNodes extracts the messages of a list of nodes, passing comments for
+// translators on to the action that follows them
 func scanNodes(nodes []prsTmpl.Node) (res []spec.Msg) {
+	var pending string // comment awaiting its action
 	for _, node := range nodes {
-		nt := node.Type().Type()
-		switch nt {
-		case prsTmpl.NodeAction:
-			an := node.(*prsTmpl.ActionNode)
-			res = append(res, scanActionNode(an)...)
-		case prsTmpl.NodePipe:
-			pn := node.(*prsTmpl.PipeNode)
-			res = append(res, scanPipeNode(pn)...)
-		case prsTmpl.NodeRange:
-			rn := node.(*prsTmpl.RangeNode)
-			res = append(res, scanNodes(rn.List.Nodes)...)
-		case prsTmpl.NodeIf:
-			in := node.(*prsTmpl.IfNode)
-			res = append(res, scanNodes(in.List.Nodes)...)
-			if in.ElseList != nil {
-				res = append(res, scanNodes(in.ElseList.Nodes)...)
+		switch n := node.(type) {
+		case *prsTmpl.CommentNode:
+			pending = strings.TrimSuffix(strings.TrimPrefix(n.Text, "/*"), "*/")
+			continue
+		case *prsTmpl.TextNode:
+			if len(bytes.TrimSpace(n.Text)) > 0 {
+				pending = "" // the comment was about the markup in between
 			}
-		default:
 			continue
 		}
+		msgs := scanNode(node)
+		switch node.(type) {
+		case *prsTmpl.ActionNode, *prsTmpl.TemplateNode:
+			for k := range msgs {
+				msgs[k] = withNote(msgs[k], pending)
+			}
+		}
+		res = append(res, msgs...)
+		pending = ""
 	}
 	return
 }
 
-func scanActionNode(an *prsTmpl.ActionNode) []spec.Msg {
-	pn := an.Pipe
-	return scanPipeNode(pn)
+// scanNode extracts the messages of a node and everything under it
+func scanNode(node prsTmpl.Node) []spec.Msg {
+	switch n := node.(type) {
+	case *prsTmpl.ListNode:
+		if n != nil {
+			return scanNodes(n.Nodes)
+		}
+	case *prsTmpl.ActionNode:
+		return scanPipeNode(n.Pipe)
+	case *prsTmpl.TemplateNode:
+		return scanPipeNode(n.Pipe)
+	case *prsTmpl.IfNode:
+		return scanBranch(&n.BranchNode)
+	case *prsTmpl.RangeNode:
+		return scanBranch(&n.BranchNode)
+	case *prsTmpl.WithNode:
+		return scanBranch(&n.BranchNode)
+	case *prsTmpl.PipeNode:
+		return scanPipeNode(n)
+	case *prsTmpl.ChainNode:
+		return scanNode(n.Node)
+	}
+	return nil
+}
+
+// scanBranch extracts the messages of an if, range or with action: those
+// of its pipeline, its body and its else branch, which holds any
+// {{else if}} or {{else with}} that follows
+func scanBranch(bn *prsTmpl.BranchNode) (res []spec.Msg) {
+	res = append(res, scanPipeNode(bn.Pipe)...)
+	res = append(res, scanNode(bn.List)...)
+	if bn.ElseList != nil {
+		res = append(res, scanNode(bn.ElseList)...)
+	}
+	return
 }
 
+// scanPipeNode extracts the messages of the commands of a pipeline and of
+// any pipelines nested in their arguments. A string constant on its own
+// in a command is passed on to the next as its last argument, as in
+// {{"Welcome" | .T.G}}.
 func scanPipeNode(pn *prsTmpl.PipeNode) (res []spec.Msg) {
-	cmds := pn.Cmds    // PipeNode.[]CommandNode
-	linePos := pn.Line // line position
-	for _, cmd := range cmds {
-		ok, fun, msgs := false, "", []spec.Msg{}
-		for k, arg := range cmd.Args {
-			switch arg.Type() {
-			case prsTmpl.NodeField, prsTmpl.NodeIdentifier, prsTmpl.NodeVariable: // func, method, var
-				ok = true // allow for loop to roll through
-				call := strings.Split(arg.String(), ".")
-				switch call[len(call)-1] { // lock in for subsequent passes
-				case gf:
-					fun = "G" // "gettext"
-				case ngf:
-					fun = "NG" // "ngettext"
-				case pgf:
-					fun = "PG" // "ngettext"
-				case npgf:
-					fun = "NPG" // "npgettext"
-				default:
-					fun = ""
-				}
-			case prsTmpl.NodeString:
-				ok = true
-			}
-			if !ok {
-				continue
-			}
-			switch arg.Type() {
-			case prsTmpl.NodePipe:
-				msgs = append(msgs, scanPipeNode(arg.(*prsTmpl.PipeNode))...)
-			case prsTmpl.NodeString:
-				switch fun {
-				case "G":
-					msgs = append(msgs, spec.Msg{Line: linePos, Id: arg.String()})
-				case "NG":
-					switch k { // 1 - singular, 2 - plural, subsequent ignored
-					case 1:
-						msgs = append(msgs, spec.Msg{Line: linePos, Id: arg.String()})
-					case 2:
-						msgs[0].IdPlural = arg.String()
-					default:
-						msgs = append(msgs, spec.Msg{Line: linePos, Id: arg.String()})
-					}
-				case "PG":
-					switch k { // 1 - context, 2 - text, subsequent ignored
-					case 1:
-						msgs = append(msgs, spec.Msg{Line: linePos, Ctxt: arg.String()})
-					case 2:
-						msgs[0].Id = arg.String()
-					default:
-						msgs = append(msgs, spec.Msg{Line: linePos, Id: arg.String()})
-					}
-				case "NPG":
-					switch k { // 1 - context, 2 - singular, 3 - plural, subsequent ignored
-					case 1:
-						msgs = append(msgs, spec.Msg{Line: linePos, Ctxt: arg.String()})
-					case 2:
-						msgs[0].Id = arg.String()
-					case 3:
-						msgs[0].IdPlural = arg.String()
-					default:
-						msgs = append(msgs, spec.Msg{Line: linePos, Id: arg.String()})
-					}
-				}
-			}
+	if pn == nil {
+		return nil
+	}
+	var prev prsTmpl.Node // string constant piped into the next command
+	for _, cmd := range pn.Cmds {
+		for _, arg := range cmd.Args {
+			res = append(res, scanNode(arg)...)
+		}
+		args := cmd.Args
+		if prev != nil {
+			args = append(args[:len(args):len(args)], prev)
+		}
+		res = append(res, scanCommand(args, pn.Line)...)
+		prev = nil
+		if len(cmd.Args) == 1 && cmd.Args[0].Type() == prsTmpl.NodeString {
+			prev = cmd.Args[0]
 		}
-		res = append(res, msgs...)
 	}
 	return
 }
 
+// scanCommand extracts the messages of a call to one of the translation
+// functions or methods, given the arguments of its command, the first of
+// which names what is called. Strings after the ones the function takes
+// are translated as well.
+func scanCommand(args []prsTmpl.Node, line int) (res []spec.Msg) {
+	if len(args) == 0 {
+		return nil
+	}
+	var name string
+	switch fn := args[0].(type) {
+	case *prsTmpl.IdentifierNode: // function
+		name = fn.Ident
+	case *prsTmpl.FieldNode: // method
+		name = fn.Ident[len(fn.Ident)-1]
+	case *prsTmpl.VariableNode: // method of a variable
+		if len(fn.Ident) > 1 {
+			name = fn.Ident[len(fn.Ident)-1]
+		}
+	case *prsTmpl.ChainNode: // method of a parenthesized value
+		if len(fn.Field) > 0 {
+			name = fn.Field[len(fn.Field)-1]
+		}
+	}
+	kind := nameKind(name)
+	roles := callArgs(kind)
+	if roles == nil || len(args) <= len(roles) {
+		return nil
+	}
+	msg := spec.Msg{Line: line}
+	for k, role := range roles {
+		s, ok := args[k+1].(*prsTmpl.StringNode)
+		if !ok {
+			return nil
+		}
+		switch role {
+		case "context":
+			msg.Ctxt = s.Quoted
+		case "text", "singular":
+			msg.Id = s.Quoted
+		case "plural":
+			msg.IdPlural = s.Quoted
+		}
+	}
+	if kind == "OG" || kind == "POG" {
+		msg = ordinalMsg(msg)
+	}
+	res = append(res, msg)
+	for _, arg := range args[len(roles)+1:] {
+		if s, ok := arg.(*prsTmpl.StringNode); ok {
+			res = append(res, spec.Msg{Line: line, Id: s.Quoted})
+		}
+	}
+	return
+}
+
+// ordinalMsg flags a message as ordinal. Catalogs get a message for each
+// ordinal form of their language in its stead (see ExpandOrdinals).
+func ordinalMsg(msg spec.Msg) spec.Msg {
+	return addComments(msg, "flag", []string{"ordinal"})
+}
+
+// note returns the lines of a comment from the one starting with the tag
+// for comments meant for translators, or nil if there is none
+func note(text string) (lines []string) {
+	found := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		found = found || strings.HasPrefix(line, tag)
+		if found && line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return
+}
+
+// withNote passes a source comment on to a message: the part meant for
+// translators as an extracted comment, and any flags it sets
+func withNote(msg spec.Msg, text string) spec.Msg {
+	if lines := note(text); len(lines) > 0 {
+		msg = addComments(msg, "extracted", lines)
+	}
+	if flags := commentFlags(text); len(flags) > 0 {
+		msg = addComments(msg, "flag", []string{strings.Join(flags, ", ")})
+	}
+	return msg
+}
+
+func addComments(msg spec.Msg, key string, lines []string) spec.Msg {
+	comments := make(spec.CommentPack)
+	for k, v := range msg.Comments {
+		comments[k] = v
+	}
+	comments[key] = append(append([]string{}, comments[key]...), lines...)
+	msg.Comments = comments
+	return msg
+}
+
 func prepMsg(msgs *[]spec.Msg) {
 	ps := string(os.PathSeparator)
-	for k, v := range *msgs {
+	var ds diagnostics
+	k := 0
+	for _, v := range *msgs {
+		// gettext reserves msgid "" for the catalog header
+		if len(v.Id) <= 2 {
+			ds.warnf(token.Position{Filename: v.Filename, Line: v.Line},
+				"empty msgid, which is reserved for the header; skipped")
+			continue
+		}
+		(*msgs)[k] = v
+
 		// prep meta
 		(*msgs)[k].Comments = make(spec.CommentPack)
+		for _, key := range []string{"extracted", "flag"} {
+			if len(v.Comments[key]) > 0 {
+				(*msgs)[k].Comments[key] = v.Comments[key]
+			}
+		}
 		ref := strings.Join(strings.Split(v.Filename, o.General.DirProject+ps), "")
 		ref += ":" + strconv.Itoa(v.Line)
 		(*msgs)[k].Comments["reference"] = append(v.Comments["reference"], ref)
 
 		// escape tilde-`quoted` strings (note that v is assigned to)
-		switch {
-		case len(v.Ctxt) > 0 && v.Ctxt[:1] == "`":
+		if len(v.Ctxt) > 0 && v.Ctxt[:1] == "`" {
 			v.Ctxt = escapeString(v.Ctxt)
-		case len(v.Id) > 0 && v.Id[:1] == "`":
+		}
+		if len(v.Id) > 0 && v.Id[:1] == "`" {
 			v.Id = escapeString(v.Id)
-		case len(v.IdPlural) > 0 && v.IdPlural[:1] == "`":
+		}
+		if len(v.IdPlural) > 0 && v.IdPlural[:1] == "`" {
 			v.IdPlural = escapeString(v.IdPlural)
 		}
 
@@ -313,7 +695,11 @@ func prepMsg(msgs *[]spec.Msg) {
 		if len(v.IdPlural) > 0 {
 			(*msgs)[k].IdPlural = v.IdPlural[1 : len(v.IdPlural)-1]
 		}
+		flagFormat(&(*msgs)[k])
+		k++
 	}
+	*msgs = (*msgs)[:k]
+	ds.report()
 }
 
 func escapeString(s string) string {
@@ -0,0 +1,645 @@
+package po
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Sam-Izdat/pogo/catalog"
+	spec "github.com/Sam-Izdat/pogo/gtspec"
+)
+
+func init() {
+	gf, ngf, pgf, npgf, ogf, pogf = "G", "NG", "PG", "NPG", "OG", "POG"
+	formatFlag = "go-format"
+}
+
+// writeFiles writes files, named by their slash-separated paths, under dir
+// and returns dir
+func writeFiles(t *testing.T, dir string, files map[string]string) string {
+	for fn, data := range files {
+		fn = filepath.Join(dir, filepath.FromSlash(fn))
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// scanSource scans a package made of one Go file and returns the messages
+// found along with any warnings
+func scanSource(t *testing.T, src string) (msgs []spec.Msg, warnings []string) {
+	return scanDir(writeFiles(t, t.TempDir(), map[string]string{"x.go": src}))
+}
+
+func scanDir(dir string) (msgs []spec.Msg, warnings []string) {
+	defer func(r func(Diagnostic)) { Report = r }(Report)
+	Report = func(d Diagnostic) { warnings = append(warnings, d.String()) }
+	return ScanGo(dir), warnings
+}
+
+func TestScanGoConstants(t *testing.T) {
+	msgs, warnings := scanSource(t, `package x
+
+const (
+	hello = "Hello, " + name
+	name  = "world"
+	menu  = "menu"
+)
+
+func f(T interface{}, s string, n int) {
+	T.G(hello)
+	T.G("multi" + "line\n")
+	T.PG(menu, "Open")
+	T.NG("one "+name, "many "+name+"s", n)
+	T.G(s)
+	T.PG(menu, s)
+	T.G("%s", s)
+}
+`)
+	want := []spec.Msg{
+		{Id: "Hello, world"},
+		{Id: `multiline\n`},
+		{Ctxt: "menu", Id: "Open"},
+		{Id: "one world", IdPlural: "many worlds"},
+		{Id: "%s"},
+	}
+	if len(msgs) != len(want) {
+		t.Fatalf("expected %d messages, got %d: %v", len(want), len(msgs), msgs)
+	}
+	for k, m := range want {
+		if msgs[k].Ctxt != m.Ctxt || msgs[k].Id != m.Id || msgs[k].IdPlural != m.IdPlural {
+			t.Errorf("message %d: expected %q/%q/%q, got %q/%q/%q", k,
+				m.Ctxt, m.Id, m.IdPlural, msgs[k].Ctxt, msgs[k].Id, msgs[k].IdPlural)
+		}
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %q", warnings)
+	}
+	for k, pos := range []string{"x.go:14:6:", "x.go:15:13:"} {
+		if !strings.Contains(warnings[k], pos) {
+			t.Errorf("expected warning at %s, got %q", pos, warnings[k])
+		}
+	}
+}
+
+func TestScanGoNotes(t *testing.T) {
+	msgs, _ := scanSource(t, `package x
+
+func f(T interface{}, n int) {
+	// TRANSLATORS: shown on the front page
+	// next to the logo
+	T.G("Welcome")
+
+	// an ordinary comment
+	T.G("Goodbye")
+
+	/* TRANSLATORS: %d is a lap */ T.OG("Lap %d", n)
+}
+`)
+	want := [][]string{
+		{"TRANSLATORS: shown on the front page", "next to the logo"},
+		nil,
+		{"TRANSLATORS: %d is a lap"},
+	}
+	if len(msgs) != len(want) {
+		t.Fatalf("expected %d messages, got %d", len(want), len(msgs))
+	}
+	for k, lines := range want {
+		if got := msgs[k].Comments["extracted"]; strings.Join(got, "\n") != strings.Join(lines, "\n") {
+			t.Errorf("%s: expected comments %q, got %q", msgs[k].Id, lines, got)
+		}
+	}
+	if !catalog.HasFlag(msgs[2], "ordinal") {
+		t.Errorf("expected ordinal flag to be kept, got %q", msgs[2].Comments["flag"])
+	}
+}
+
+func TestOrdinals(t *testing.T) {
+	msgs, _ := scanSource(t, `package x
+
+func f(T interface{}, n int) {
+	T.G("1st")
+	T.OG("1st", n)
+	// TRANSLATORS: a place
+	T.OG("1st", n)
+	T.POG("race", "1st", n)
+}
+`)
+	RemoveDuplicates(&msgs)
+	if len(msgs) != 3 {
+		t.Fatalf("expected G and OG to stay apart as 3 messages, got %d", len(msgs))
+	}
+	var got []string
+	for _, m := range ExpandOrdinals(msgs, "en") {
+		if m.IdPlural != "" || catalog.HasFlag(m, "ordinal") {
+			t.Errorf("%q: expected a singular message without the ordinal flag", m.Ctxt)
+		}
+		got = append(got, m.Ctxt+"|"+m.Id+": "+strings.Join(m.Comments["extracted"], "; "))
+	}
+	want := []string{
+		"|1st: ",
+		"ordinal:one|1st: TRANSLATORS: a place; ordinal form for 1, 21, 31, 41, ...",
+		"ordinal:two|1st: TRANSLATORS: a place; ordinal form for 2, 22, 32, 42, ...",
+		"ordinal:few|1st: TRANSLATORS: a place; ordinal form for 3, 23, 33, 43, ...",
+		"ordinal:other|1st: TRANSLATORS: a place; ordinal form for 0, 4, 5, 6, ...",
+		"ordinal:one|race|1st: ordinal form for 1, 21, 31, 41, ...",
+		"ordinal:two|race|1st: ordinal form for 2, 22, 32, 42, ...",
+		"ordinal:few|race|1st: ordinal form for 3, 23, 33, 43, ...",
+		"ordinal:other|race|1st: ordinal form for 0, 4, 5, 6, ...",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if n := len(ExpandOrdinals(msgs, "")); n != 1+2*6 {
+		t.Errorf("expected the template to hold every category, got %d messages", n)
+	}
+	if pofile := Compile(nil, ExpandOrdinals(msgs, "en"), "en", "English", ""); strings.Contains(pofile, "msgstr[") {
+		t.Errorf("expected no plural entries, got\n%s", pofile)
+	}
+}
+
+func TestEmptyMsgid(t *testing.T) {
+	msgs, warnings := scanSource(t, "package x\n\nfunc f(T interface{}) {\n"+
+		"\tT.G(\"\")\n\tT.G(``)\n\tT.PG(\"menu\", \"\")\n\tT.G(\"Hello\")\n}\n")
+	if got := strings.Join(msgKeys(msgs), " "); got != "|Hello|" {
+		t.Errorf("expected the empty msgids to be dropped, got %s", got)
+	}
+	if len(warnings) != 3 {
+		t.Errorf("expected a warning for each empty msgid, got %v", warnings)
+	}
+
+	// a stray empty msgid must not take the place of the header
+	stray := []spec.Msg{{Str: "Language: xx"}, {Id: "Hello"}}
+	if pofile := Compile(nil, stray, "", "", ""); !strings.Contains(pofile, "Project-Id-Version:") {
+		t.Errorf("expected a generated header, got\n%s", pofile)
+	}
+
+	def := []spec.Msg{{Str: `Project-Id-Version: old\nPOT-Creation-Date: then\n`}, {Id: "Hello", Str: "Bonjour"}}
+	res, header, _ := Merge(def, []spec.Msg{{Id: "Hello"}})
+	if header == nil || !strings.HasPrefix(header.Str, `Project-Id-Version: old\n`) || strings.Contains(header.Str, "then") {
+		t.Fatalf("expected the refreshed header of the catalog, got %+v", header)
+	}
+	if len(res) != 1 || res[0].Str != "Bonjour" {
+		t.Errorf("expected the header apart from the messages, got %+v", res)
+	}
+	if pofile := Compile(header, res, "fr", "French", ""); !strings.Contains(pofile, "Project-Id-Version: old") ||
+		strings.Contains(pofile, "Report-Msgid-Bugs-To") {
+		t.Errorf("expected the header of the catalog to be kept, got\n%s", pofile)
+	}
+}
+
+func TestScanTmplNotes(t *testing.T) {
+	msgs := scanTmpl(t, `{{define "page"}}
+	<h1>
+	{{/* TRANSLATORS: page title */}}
+	{{.T.G "Welcome"}}</h1>
+	<p>{{.T.G "Goodbye"}}</p>
+	<p>{{- /* TRANSLATORS: trimmed */ -}}
+	{{.T.G "Later"}}</p>
+	{{/* TRANSLATORS: about the image */}}
+	<img src="logo.png">
+	<p>{{.T.G "Caption"}}</p>
+	{{/* TRANSLATORS: dropped */}}<br>{{.T.G "Inline"}}
+{{end}}`)
+	if len(msgs) != 5 {
+		t.Fatalf("expected 5 messages, got %d", len(msgs))
+	}
+	if got := msgs[0].Comments["extracted"]; len(got) != 1 || got[0] != "TRANSLATORS: page title" {
+		t.Errorf("expected the comment on Welcome, got %q", got)
+	}
+	if got := msgs[1].Comments["extracted"]; got != nil {
+		t.Errorf("expected no comment on Goodbye, got %q", got)
+	}
+	if got := msgs[2].Comments["extracted"]; len(got) != 1 || got[0] != "TRANSLATORS: trimmed" {
+		t.Errorf("expected the comment on Later, got %q", got)
+	}
+	for _, m := range msgs[3:] {
+		if got := m.Comments["extracted"]; got != nil {
+			t.Errorf("expected markup in between to drop the comment on %s, got %q", m.Id, got)
+		}
+	}
+}
+
+func TestScanGoTyped(t *testing.T) {
+	dir := writeFiles(t, t.TempDir(), map[string]string{"x.go": `package x
+
+import "github.com/Sam-Izdat/pogo/translate"
+
+type other struct{}
+
+func (other) G(s string) string { return s }
+
+type Locale struct{ translate.Translator }
+
+func (l Locale) Say(ctx, s string) string { return s }
+
+func Tr(s string) string { return s }
+
+func f(T translate.Translator, l Locale, o other, x interface{ G(string) string }, u undefined) {
+	T.G("method")
+	l.G("promoted")
+	o.G("other")
+	x.G("interface")
+	Tr("wrapper")
+	l.Say("ctx", "wrapper method")
+	u.G("unresolved")
+}
+`})
+	defer func(t bool, f map[string]string) { typed, typedFuncs = t, f }(typed, typedFuncs)
+	typed = true
+	typedFuncs = map[string]string{
+		translatorType + ".G":                "G",
+		importPath(dir, "x") + ".Tr":         "G",
+		importPath(dir, "x") + ".Locale.Say": "PG",
+	}
+	msgs, _ := scanDir(dir)
+	var got []string
+	for _, m := range msgs {
+		got = append(got, m.Id)
+	}
+	want := "method promoted wrapper wrapper method unresolved"
+	if strings.Join(got, " ") != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func scanTmpl(t *testing.T, s string) []spec.Msg {
+	msgs, err := scanTmplString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msgs
+}
+
+// msgKeys renders messages as "context|text|plural" for comparison
+func msgKeys(msgs []spec.Msg) (res []string) {
+	for _, m := range msgs {
+		res = append(res, m.Ctxt+"|"+m.Id+"|"+m.IdPlural)
+	}
+	return
+}
+
+func TestScanTmplConstructs(t *testing.T) {
+	tests := []struct {
+		name, tmpl string
+		want       []string
+	}{
+		{"action", `{{.T.G "a"}}`, []string{`|"a"|`}},
+		{"raw string", "{{.T.G `a`}}", []string{"|`a`|"}},
+		{"function", `{{G "a"}}`, []string{`|"a"|`}},
+		{"variable", `{{$.T.G "a"}}{{$t := .T}}{{$t.G "b"}}`, []string{`|"a"|`, `|"b"|`}},
+		{"chain", `{{(.T).G "a"}}`, []string{`|"a"|`}},
+		{"all kinds", `{{.T.NG "a" "as" 2}}{{.T.PG "c" "b"}}{{.T.NPG "c" "d" "ds" 2}}{{.T.OG "e" 1}}{{.T.POG "c" "f" 1}}`,
+			[]string{`|"a"|"as"`, `"c"|"b"|`, `"c"|"d"|"ds"`, `|"e"|`, `"c"|"f"|`}},
+		{"extra strings", `{{.T.PG "c" "%s, %s" "a" "b"}}`, []string{`"c"|"%s, %s"|`, `|"a"|`, `|"b"|`}},
+		{"non-constant", `{{.T.G .Title}}{{.T.PG .Ctx "a"}}`, nil},
+		{"other functions", `{{printf "%s" "a"}}{{.T.X "b"}}`, nil},
+		{"nested pipeline", `{{printf "%s" (.T.G "a")}}`, []string{`|"a"|`}},
+		{"nested twice", `{{.T.G "a %s" (printf "%s" (.T.G "b"))}}`, []string{`|"b"|`, `|"a %s"|`}},
+		{"piped string", `{{"a" | .T.G}}`, []string{`|"a"|`}},
+		{"piped last argument", `{{"b" | .T.PG "c"}}`, []string{`"c"|"b"|`}},
+		{"piped non-constant", `{{.Title | .T.G}}{{.T.G "a" | printf "%s"}}`, []string{`|"a"|`}},
+		{"if", `{{if .T.G "a"}}{{.T.G "b"}}{{else}}{{.T.G "c"}}{{end}}`, []string{`|"a"|`, `|"b"|`, `|"c"|`}},
+		{"range else", `{{range .X}}{{.T.G "a"}}{{else}}{{$.T.G "b"}}{{end}}`, []string{`|"a"|`, `|"b"|`}},
+		{"with else", `{{with .T.G "a"}}{{.}}{{$.T.G "b"}}{{else}}{{$.T.G "c"}}{{end}}`, []string{`|"a"|`, `|"b"|`, `|"c"|`}},
+		{"nested blocks", `{{range .X}}{{with .Y}}{{if .Z}}{{$.T.G "a"}}{{end}}{{end}}{{end}}`, []string{`|"a"|`}},
+		{"template argument", `{{template "other" .T.G "a"}}`, []string{`|"a"|`}},
+		{"template pipeline", `{{template "other" (.T.PG "c" "a")}}`, []string{`"c"|"a"|`}},
+		{"block", `{{block "b" .}}{{.T.G "a"}}{{end}}{{.T.G "b"}}`, []string{`|"b"|`, `|"a"|`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := msgKeys(scanTmpl(t, `{{define "x"}}`+tt.tmpl+`{{end}}`))
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("%s\nexpected %q\ngot      %q", tt.tmpl, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestScanTmplDefines(t *testing.T) {
+	msgs := scanTmpl(t, `{{define "b"}}{{.T.G "b"}}{{end}}
+{{define "a"}}{{.T.G "a"}}{{end}}
+{{define "c"}}{{.T.G "c"}}{{end}}`)
+	if got := strings.Join(msgKeys(msgs), " "); got != `|"b"| |"a"| |"c"|` {
+		t.Errorf("expected definitions in source order, got %s", got)
+	}
+	if msgs[1].Line != 2 {
+		t.Errorf("expected line 2, got %d", msgs[1].Line)
+	}
+}
+
+// Templates using syntax the parser once rejected
+func TestScanTmplSyntax(t *testing.T) {
+	tests := []struct {
+		name, tmpl string
+		want       []string
+	}{
+		{"no definitions", `<p>{{.T.G "a"}}</p>`, []string{`|"a"|`}},
+		{"definitions and content", `{{define "x"}}{{.T.G "a"}}{{end}}<p>{{.T.G "b"}}</p>`, []string{`|"b"|`, `|"a"|`}},
+		{"trim markers", `<p>{{- .T.G "a" -}}</p>{{- .T.G "b"}}`, []string{`|"a"|`, `|"b"|`}},
+		{"trimmed comment", "{{- /* a */ -}}\n{{.T.G \"a\"}}", []string{`|"a"|`}},
+		{"break and continue", `{{range .X}}{{if .Y}}{{break}}{{end}}{{if .Z}}{{continue}}{{end}}{{$.T.G "a"}}{{end}}`, []string{`|"a"|`}},
+		{"else if", `{{if .X}}{{.T.G "a"}}{{else if .Y}}{{.T.G "b"}}{{else}}{{.T.G "c"}}{{end}}`, []string{`|"a"|`, `|"b"|`, `|"c"|`}},
+		{"else with", `{{with .X}}{{$.T.G "a"}}{{else with .T.G "b"}}{{$.T.G "c"}}{{else}}{{.T.G "d"}}{{end}}`,
+			[]string{`|"a"|`, `|"b"|`, `|"c"|`, `|"d"|`}},
+		{"variables", `{{$x := 1}}{{$x = 2}}{{range $i, $e := .X}}{{$.T.G "a"}}{{end}}`, []string{`|"a"|`}},
+		{"range over integer", `{{range 3}}{{$.T.G "a"}}{{end}}`, []string{`|"a"|`}},
+		{"undefined function", `{{myfunc (.T.G "a") | other}}`, []string{`|"a"|`}},
+		{"field of result", `{{(.T.G "a").Len}}`, []string{`|"a"|`}},
+		{"decimal quantity", `{{.T.NG "a" "as" 2.5}}`, []string{`|"a"|"as"`}},
+		{"multi-line action", "{{.T.NG\n\t\"a\"\n\t\"as\"\n\t.N}}", []string{`|"a"|"as"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := msgKeys(scanTmpl(t, tt.tmpl))
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("%s\nexpected %q\ngot      %q", tt.tmpl, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestScanTmplDelims(t *testing.T) {
+	defer func(l, r string) { lDelim, rDelim = l, r }(lDelim, rDelim)
+	lDelim, rDelim = "[[", "]]"
+	got := msgKeys(scanTmpl(t, `[[.T.G "a"]] {{.T.G "b"}} [[- /* c */ -]]`))
+	if len(got) != 1 || got[0] != `|"a"|` {
+		t.Errorf("expected only the message in custom delimiters, got %q", got)
+	}
+}
+
+func TestScanDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.go":        "package x\n\nfunc f(T interface{}) { T.G(\"a\") }\n",
+		"b.go":        "package x\n\nfunc g() {\n\tT.G(\"b\"\n}\n",
+		"good.html":   `{{.T.G "c"}}`,
+		"broken.html": "<p>\n{{.T.G \"d\"}\n{{end}}",
+	}
+	writeFiles(t, dir, files)
+	defer func(r func(Diagnostic), exts []string) { Report, o.Parsing.TmplExts = r, exts }(Report, o.Parsing.TmplExts)
+	o.Parsing.TmplExts = []string{"html"}
+	var diags []Diagnostic
+	Report = func(d Diagnostic) { diags = append(diags, d) }
+
+	msgs := append(ScanGo(dir), ScanTmpl(dir)...)
+	if got := strings.Join(msgKeys(msgs), " "); got != `|a| |c|` {
+		t.Errorf("expected the messages of the good files, got %s", got)
+	}
+	first := make(map[string]Diagnostic) // by file
+	for _, d := range diags {
+		if _, ok := first[filepath.Base(d.Pos.Filename)]; !ok {
+			first[filepath.Base(d.Pos.Filename)] = d
+		}
+	}
+	if len(first) != 2 {
+		t.Fatalf("expected diagnostics for the broken files, got %v", diags)
+	}
+	for fn, line := range map[string]int{"b.go": 4, "broken.html": 2} {
+		if d := first[fn]; !d.Err || d.Pos.Line != line {
+			t.Errorf("expected an error at %s:%d, got %+v", fn, line, d)
+		}
+	}
+	if first["b.go"].Pos.Column == 0 {
+		t.Errorf("expected a column for Go errors, got %v", first["b.go"])
+	}
+}
+
+func TestScanFilter(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.go":              "package x\n\nfunc f(T interface{}) { T.G(\"a\") }\n",
+		"a_test.go":         "package x\n\nfunc g(T interface{}) { T.G(\"test\") }\n",
+		"gen.go":            "// Code generated by hand. DO NOT EDIT.\n\npackage x\n\nfunc h(T interface{}) { T.G(\"gen\") }\n",
+		"vendor/v/v.go":     "package v\n\nfunc f(T interface{}) { T.G(\"vendor\") }\n",
+		"old/o.go":          "package o\n\nfunc f(T interface{}) { T.G(\"old\") }\n",
+		"sub/POGO.toml":     "",
+		"sub/s.go":          "package s\n\nfunc f(T interface{}) { T.G(\"sub\") }\n",
+		"sub/deep/d.go":     "package d\n\nfunc f(T interface{}) { T.G(\"deep\") }\n",
+		"sub/t.html":        `{{.T.G "subtmpl"}}`,
+		"web/p.html":        `{{.T.G "page"}}`,
+		"web/gen.html":      "{{/* Code generated by hand. DO NOT EDIT. */}}\n{{.T.G \"gentmpl\"}}",
+		"web/draft/d.html":  `{{.T.G "draft"}}`,
+		"web/draft/ok.html": `{{.T.G "kept"}}`,
+		IgnoreFN:            "# old code\nold/\n\n/web/draft/d.html\n",
+	}
+	writeFiles(t, dir, files)
+	parsing := o.Parsing
+	defer func() { o.Parsing = parsing }()
+	o.Parsing.TmplExts = []string{"html"}
+	scan := func() string {
+		msgs, warnings := scanDir(dir)
+		if len(warnings) > 0 {
+			t.Errorf("unexpected diagnostics: %v", warnings)
+		}
+		keys := msgKeys(append(msgs, ScanTmpl(dir)...))
+		sort.Strings(keys)
+		return strings.Join(keys, " ")
+	}
+
+	if got, want := scan(), `|a| |gentmpl| |gen| |kept| |page| |test|`; got != want {
+		t.Errorf("by default, expected %s, got %s", want, got)
+	}
+	o.Parsing.SkipTest, o.Parsing.SkipGen = true, true
+	if got, want := scan(), `|a| |kept| |page|`; got != want {
+		t.Errorf("skipping tests and generated files, expected %s, got %s", want, got)
+	}
+	o.Parsing.Include = []string{"web/**/*.html"}
+	o.Parsing.Exclude = []string{"draft"}
+	if got, want := scan(), `|page|`; got != want {
+		t.Errorf("with include and exclude, expected %s, got %s", want, got)
+	}
+	o.Parsing.Include, o.Parsing.Exclude = nil, []string{}
+	if got, want := scan(), `|a| |kept| |page| |vendor|`; got != want {
+		t.Errorf("with nothing excluded, expected %s, got %s", want, got)
+	}
+}
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		dir, want     bool
+	}{
+		{"*.html", "a.html", false, true},
+		{"*.html", "web/a/b.html", false, true},
+		{"vendor/", "a/vendor", true, true},
+		{"vendor/", "vendor", false, false},
+		{"web/*.html", "web/a.html", false, true},
+		{"web/*.html", "web/a/b.html", false, false},
+		{"web/*.html", "x/web/a.html", false, false},
+		{"/a.html", "a.html", false, true},
+		{"/a.html", "web/a.html", false, false},
+		{"web/**/*.html", "web/a.html", false, true},
+		{"web/**/*.html", "web/a/b/c.html", false, true},
+		{"**/gen", "a/b/gen", true, true},
+		{"web/**", "web/a/b", false, true},
+	}
+	for _, test := range tests {
+		p, err := newPattern(test.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.match(test.path, test.dir); got != test.want {
+			t.Errorf("%q matching %q: expected %v, got %v", test.pattern, test.path, test.want, got)
+		}
+	}
+	if _, err := newPattern("a[/"); err == nil {
+		t.Error("expected an error for a bad pattern")
+	}
+}
+
+func TestScanOrder(t *testing.T) {
+	dir := t.TempDir()
+	files := make(map[string]string)
+	var want []string
+	for k := 0; k < 30; k++ {
+		name := fmt.Sprintf("d%02d", k)
+		files[name+"/b.go"] = fmt.Sprintf("package p\n\nfunc f(T interface{}) { T.G(%q) }\n", name+"b")
+		files[name+"/a.go"] = fmt.Sprintf("package p\n\nfunc g(T interface{}) { T.G(%q) }\n", name+"a")
+		want = append(want, "|"+name+"a|", "|"+name+"b|")
+	}
+	writeFiles(t, dir, files)
+	for run := 0; run < 3; run++ {
+		msgs, _ := scanDir(dir)
+		if got := strings.Join(msgKeys(msgs), " "); got != strings.Join(want, " ") {
+			t.Fatalf("expected messages in the order of their files, got %s", got)
+		}
+	}
+}
+
+func TestScanCache(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a/a.go":      "package a\n\nconst hi = \"Hi\"\n\nfunc f(T interface{}) { T.G(hi) }\n",
+		"a/b.go":      "package a\n\nfunc g(T interface{}, s string) { T.G(s) }\n",
+		"c/c.go":      "package c\n\nfunc f(T interface{}) { T.G(\"c\"\n",
+		"web/p.html":  `{{.T.G "page"}}`,
+		"web/e.html":  `{{.T.G "e"}`,
+		"web/ok.html": `{{.T.G "ok"}}`,
+	})
+	defer func(d string, exts []string) { CacheDir, o.Parsing.TmplExts = d, exts }(CacheDir, o.Parsing.TmplExts)
+	CacheDir = t.TempDir()
+	o.Parsing.TmplExts = []string{"html"}
+	scan := func() (string, []string) {
+		msgs, warnings := scanDir(dir)
+		defer func(r func(Diagnostic)) { Report = r }(Report)
+		Report = func(d Diagnostic) { warnings = append(warnings, d.String()) }
+		return strings.Join(msgKeys(append(msgs, ScanTmpl(dir)...)), " "), warnings
+	}
+
+	first, firstWarnings := scan()
+	if first != `|Hi| |ok| |page|` || len(firstWarnings) != 3 {
+		t.Fatalf("unexpected first scan: %s %v", first, firstWarnings)
+	}
+	c := openCache(dir, "go")
+	a := filepath.Join(dir, "a")
+	files := map[string]string{}
+	for _, fn := range []string{"a.go", "b.go"} {
+		buf, _ := os.ReadFile(filepath.Join(a, fn))
+		files[filepath.Join(a, fn)] = hashBytes(buf)
+	}
+	if c.lookup(a, files) == nil {
+		t.Fatal("expected the directory to be cached")
+	}
+
+	again, againWarnings := scan()
+	if again != first || strings.Join(againWarnings, "\n") != strings.Join(firstWarnings, "\n") {
+		t.Errorf("expected the same from the cache, got %s %v", again, againWarnings)
+	}
+
+	writeFiles(t, dir, map[string]string{
+		"a/b.go":     "package a\n\nconst bye = \"Bye\"\n",
+		"a/a.go":     "package a\n\nfunc f(T interface{}) { T.G(bye) }\n",
+		"web/p.html": `{{.T.G "changed"}}`,
+	})
+	if got, _ := scan(); got != `|Bye| |ok| |changed|` {
+		t.Errorf("expected changed files to be scanned again, got %s", got)
+	}
+}
+
+func TestIsGoFormat(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"Hello", false},
+		{"Hello, %s", true},
+		{"%d files", true},
+		{"%[2]d of %[1]d", true},
+		{"%-10.2f", true},
+		{"%*d", true},
+		{"%[1]*.[2]*[3]f", true},
+		{"%#v", true},
+		{"%+q", true},
+		{"100%", false},
+		{"100%%", false},
+		{"50% off", false},
+		{"%% %s", true},
+		{"%!", false},
+	}
+	for _, test := range tests {
+		if got := isGoFormat(test.s); got != test.want {
+			t.Errorf("isGoFormat(%q): expected %v, got %v", test.s, test.want, got)
+		}
+	}
+}
+
+func TestScanFormatFlags(t *testing.T) {
+	dir := writeFiles(t, t.TempDir(), map[string]string{
+		"x.go": `package x
+
+func f(T interface{}, n int) {
+	T.G("Hello")
+	T.G("Hello, %s", "world")
+	T.NG("%d file", "%d files", n)
+	T.G("%[2]s %[1]s")
+	T.G("100%% done")
+	// xgettext:no-go-format
+	T.G("%s literally")
+	//xgettext:no-go-format
+	T.PG("menu", "Save %s")
+	/* TRANSLATORS: not what it seems
+	   xgettext: no-go-format */
+	T.G("%d%")
+	T.G("Dup %d")
+	// xgettext:no-go-format
+	T.G("Dup %d")
+}
+`,
+		"p.html": `{{.T.G "Page %d"}} {{/* xgettext:no-go-format */}}{{.T.G "%v"}}`,
+	})
+	defer func(exts []string) { o.Parsing.TmplExts = exts }(o.Parsing.TmplExts)
+	o.Parsing.TmplExts = []string{"html"}
+	msgs, _ := scanDir(dir)
+	msgs = append(msgs, ScanTmpl(dir)...)
+	RemoveDuplicates(&msgs)
+	var got []string
+	for _, m := range msgs {
+		got = append(got, m.Id+": "+strings.Join(m.Comments["flag"], "; "))
+	}
+	want := []string{
+		"Hello: ",
+		"Hello, %s: go-format",
+		"world: ",
+		"%d file: go-format",
+		"%[2]s %[1]s: go-format",
+		"100%% done: ",
+		"%s literally: no-go-format",
+		"Save %s: no-go-format",
+		"%d%: no-go-format",
+		"Dup %d: no-go-format",
+		"Page %d: go-format",
+		"%v: no-go-format",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected flags\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
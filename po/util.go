@@ -1,7 +1,9 @@
 package po
 
 import (
+    "strconv"
     "strings"
+    "github.com/Sam-Izdat/pogo/catalog"
     spec "github.com/Sam-Izdat/pogo/gtspec"
 )
 
@@ -40,20 +42,95 @@ func foldLine(str string) (res []string) {
     return
 }
 
-// Removes duplicate messages (identical ctxt and id); consolidates references
+// Removes duplicate messages (identical ctxt and id, an ordinal message only
+// duplicating another); consolidates references
 func RemoveDuplicates(msgs *[]spec.Msg) {
     found := make(map[string]int)
     j := 0
     for i, x := range *msgs {
-        if found[x.Ctxt+"\x04"+x.Id] == 0 {
-            found[x.Ctxt+"\x04"+x.Id] = j+1
+        key := x.Ctxt+"\x04"+x.Id
+        if catalog.HasFlag(x, "ordinal") {
+            key = spec.OrdinalPrefix+key
+        }
+        if found[key] == 0 {
+            found[key] = j+1
             (*msgs)[j] = (*msgs)[i]
             j++
         } else {
-            (*msgs)[found[x.Ctxt+"\x04"+x.Id]-1].Comments["reference"] = append(
-                (*msgs)[found[x.Ctxt+"\x04"+x.Id]-1].Comments["reference"], 
+            (*msgs)[found[key]-1].Comments["reference"] = append(
+                (*msgs)[found[key]-1].Comments["reference"], 
                 x.Comments["reference"]...)
+            first := (*msgs)[found[key]-1].Comments
+            for _, line := range x.Comments["extracted"] {
+                if !hasString(first["extracted"], line) {
+                    first["extracted"] = append(first["extracted"], line)
+                }
+            }
+            // flags are merged, an occurrence declining a format winning
+            m := &(*msgs)[found[key]-1]
+            for _, line := range x.Comments["flag"] {
+                for _, f := range strings.Split(line, ",") {
+                    addFlag(m, strings.TrimSpace(f))
+                }
+            }
+            if catalog.HasFlag(*m, "no-"+formatFlag) {
+                removeFlag(m, formatFlag)
+            }
         }
     }
     *msgs = (*msgs)[:j]
-}
\ No newline at end of file
+}
+
+// ExpandOrdinals replaces each ordinal message with a message for each of
+// the ordinal forms of target, as found by gtspec.GetOrdinalForms, whose
+// msgctxt names the form (see gtspec.OrdinalContext). A catalog without a
+// target, i.e. the template, gets a message for every CLDR category.
+func ExpandOrdinals(msgs []spec.Msg, target string) (res []spec.Msg) {
+    forms := []spec.PluralCategory{spec.PluralZero, spec.PluralOne, spec.PluralTwo,
+        spec.PluralFew, spec.PluralMany, spec.PluralOther}
+    if target != "" {
+        forms = spec.GetOrdinalForms(target)
+    }
+    for _, msg := range msgs {
+        if !catalog.HasFlag(msg, "ordinal") {
+            res = append(res, msg)
+            continue
+        }
+        for _, cat := range forms {
+            form := msg
+            form.Ctxt = spec.OrdinalContext(cat, msg.Ctxt)
+            form.Comments = make(spec.CommentPack)
+            for k, v := range msg.Comments {
+                form.Comments[k] = v
+            }
+            removeFlag(&form, "ordinal")
+            if target != "" {
+                form.Comments["extracted"] = append(append([]string{},
+                    msg.Comments["extracted"]...), ordinalExamples(target, cat))
+            }
+            res = append(res, form)
+        }
+    }
+    return
+}
+
+// ordinalExamples tells translators which numbers take an ordinal form,
+// e.g. "ordinal form for 2, 22, 32, ..."
+func ordinalExamples(target string, cat spec.PluralCategory) string {
+    var nums []string
+    for n := 0; n < 1000 && len(nums) < 4; n++ {
+        op, _ := spec.OperandsOf(n)
+        if c, _ := spec.GetOrdinalCategory(target, op); c == cat {
+            nums = append(nums, strconv.Itoa(n))
+        }
+    }
+    return "ordinal form for "+strings.Join(nums, ", ")+", ..."
+}
+
+func hasString(a []string, s string) bool {
+    for _, v := range a {
+        if v == s { return true }
+    }
+    return false
+}
+
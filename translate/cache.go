@@ -0,0 +1,113 @@
+package translate
+
+import (
+	gt "github.com/Sam-Izdat/pogo/deps/gettext"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// catalogCache holds the catalogs loaded so far, keyed by locale. It is
+// shared by a POGOCtrl, its copies and all of its translators, and is safe
+// for concurrent use: lookups only take a read lock, and each locale is
+// loaded by one goroutine at a time while any others wait for the result.
+// Failures are remembered too, so that a broken catalog is not read again
+// on every request.
+type catalogCache struct {
+	mu      sync.RWMutex
+	entries map[string]*cacheEntry
+	stamps  sync.Map // locale -> stamp of the files last read, see POGOCtrl.stamp
+}
+
+type cacheEntry struct {
+	mu      sync.Mutex // held while loading
+	catalog atomic.Pointer[gt.Catalog]
+	err     error // why loading failed, guarded by mu
+	failed  atomic.Bool
+}
+
+func newCatalogCache() *catalogCache {
+	return &catalogCache{entries: make(map[string]*cacheEntry)}
+}
+
+// get returns the catalog loaded for a locale, or nil
+func (c *catalogCache) get(locale string) *gt.Catalog {
+	c.mu.RLock()
+	e, ok := c.entries[locale]
+	c.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return e.catalog.Load()
+}
+
+// load makes sure the catalog for a locale is loaded, calling read to do
+// so if it hasn't been tried yet, and returns the error read returned, if
+// any. Should read panic, the next call will try again.
+func (c *catalogCache) load(locale string, read func() (*gt.Catalog, error)) error {
+	e := c.entry(locale)
+	if e.catalog.Load() != nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.catalog.Load() != nil || e.failed.Load() {
+		return e.err // settled while we were waiting
+	}
+	cat, err := read()
+	if err != nil {
+		e.err = err
+		e.failed.Store(true)
+		return err
+	}
+	e.catalog.Store(cat)
+	return nil
+}
+
+// reload reads the catalog for a locale again and swaps it in. If read
+// fails, a catalog loaded earlier stays in place.
+func (c *catalogCache) reload(locale string, read func() (*gt.Catalog, error)) error {
+	e := c.entry(locale)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	cat, err := read()
+	if err != nil {
+		if e.catalog.Load() == nil {
+			e.err = err
+			e.failed.Store(true)
+		}
+		return err
+	}
+	e.catalog.Store(cat)
+	e.err = nil
+	e.failed.Store(false)
+	return nil
+}
+
+// locales lists the locales whose catalogs were loaded or attempted
+func (c *catalogCache) locales() (res []string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for k := range c.entries {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return
+}
+
+// entry returns the entry for a locale, creating it if need be
+func (c *catalogCache) entry(locale string) *cacheEntry {
+	c.mu.RLock()
+	e, ok := c.entries[locale]
+	c.mu.RUnlock()
+	if ok {
+		return e
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok = c.entries[locale]; !ok {
+		e = &cacheEntry{}
+		c.entries[locale] = e
+	}
+	return e
+}
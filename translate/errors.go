@@ -0,0 +1,41 @@
+package translate
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of Error, to be tested for with errors.Is
+var (
+	ErrMissingConfig  = errors.New("configuration not found")
+	ErrInvalidConfig  = errors.New("configuration could not be parsed")
+	ErrMissingCatalog = errors.New("catalog not found or unreadable")
+	ErrCorruptCatalog = errors.New("catalog is corrupt")
+)
+
+// Error describes a failure to load the configuration or a catalog
+type Error struct {
+	Kind   error  // one of the Err* values
+	Locale string // locale of the catalog, if any
+	Path   string // file involved
+	Err    error  // underlying error
+}
+
+func (e *Error) Error() string {
+	var s string
+	if e.Locale != "" {
+		s = fmt.Sprintf("pogo: %s (%s): %s", e.Kind, e.Locale, e.Path)
+	} else {
+		s = fmt.Sprintf("pogo: %s: %s", e.Kind, e.Path)
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// Unwrap makes both the kind and the underlying error visible to
+// errors.Is and errors.As.
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
@@ -0,0 +1,91 @@
+package translate
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Resolver extracts locale preferences from an HTTP request, either as a
+// single locale ("pt_BR", "pt-br") or as an Accept-Language style list
+// ("pt-BR,pt;q=0.8"). It returns the empty string if it has nothing to say.
+type Resolver func(r *http.Request) string
+
+type ctxKey struct{}
+
+// Middleware wraps an HTTP handler so that every request carries a
+// Translator in its context, to be retrieved with FromContext. Resolvers
+// are consulted in order and the first one that names a supported locale
+// decides; if none does, the default locale is used. Without any
+// resolvers, the Accept-Language header alone is consulted.
+func (p POGOCtrl) Middleware(next http.Handler, resolvers ...Resolver) http.Handler {
+	if p.o.General.ProjectFN == "" {
+		panic("no pogo configuration loaded")
+	}
+	if len(resolvers) == 0 {
+		resolvers = []Resolver{ByAcceptLanguage()}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := p.def
+		for _, resolve := range resolvers {
+			if v := resolve(r); v != "" {
+				if match, ok := p.match(parseAcceptLanguage(v)); ok {
+					locale = match
+					break
+				}
+			}
+		}
+		ctx := NewContext(r.Context(), p.newTranslator(locale))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// NewContext returns a copy of ctx carrying the translator t
+func NewContext(ctx context.Context, t Translator) context.Context {
+	return context.WithValue(ctx, ctxKey{}, t)
+}
+
+// FromContext returns the translator carried by ctx, as stored by
+// Middleware or NewContext, and whether there was one.
+func FromContext(ctx context.Context) (Translator, bool) {
+	t, ok := ctx.Value(ctxKey{}).(Translator)
+	return t, ok
+}
+
+// ByPathPrefix resolves the locale from the first segment of the URL path,
+// e.g. "/pt-br/about". Stripping the prefix, if need be, is left to the
+// router.
+func ByPathPrefix() Resolver {
+	return func(r *http.Request) string {
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		if i := strings.Index(path, "/"); i >= 0 {
+			path = path[:i]
+		}
+		return path
+	}
+}
+
+// ByQuery resolves the locale from a URL query parameter, e.g. "?lang=ru"
+func ByQuery(param string) Resolver {
+	return func(r *http.Request) string {
+		return r.URL.Query().Get(param)
+	}
+}
+
+// ByCookie resolves the locale from the value of a cookie
+func ByCookie(name string) Resolver {
+	return func(r *http.Request) string {
+		c, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return c.Value
+	}
+}
+
+// ByAcceptLanguage resolves the locale from the Accept-Language header
+func ByAcceptLanguage() Resolver {
+	return func(r *http.Request) string {
+		return r.Header.Get("Accept-Language")
+	}
+}
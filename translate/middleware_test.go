@@ -0,0 +1,102 @@
+package translate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serve runs a request through the middleware and returns the locale of
+// the translator the handler found in the request context
+func serve(t *testing.T, h func(http.Handler) http.Handler, r *http.Request) string {
+	var locale string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tr, ok := FromContext(r.Context())
+		if !ok {
+			t.Fatal("no translator in the request context")
+		}
+		locale = tr.Locale
+	})
+	h(next).ServeHTTP(httptest.NewRecorder(), r)
+	return locale
+}
+
+func TestMiddlewareResolvers(t *testing.T) {
+	p := negotiator(t, "en", "en", "ru", "pt_BR", "fr").Lenient(nil)
+	chain := func(next http.Handler) http.Handler {
+		return p.Middleware(next, ByQuery("lang"), ByCookie("lang"), ByAcceptLanguage())
+	}
+	tests := []struct {
+		name, url, cookie, header string
+		want                      string
+	}{
+		{"nothing", "/", "", "", "en"},
+		{"query", "/?lang=ru", "fr", "pt-BR", "ru"},
+		{"query tag", "/?lang=pt-br", "", "", "pt_BR"},
+		{"cookie", "/", "fr", "pt-BR", "fr"},
+		{"header", "/", "", "pt-BR,pt;q=0.8", "pt_BR"},
+		{"header quality", "/", "", "ja, ru;q=0.2, fr;q=0.5", "fr"},
+		{"unsupported query falls through", "/?lang=ja", "ru", "", "ru"},
+		{"unsupported cookie falls through", "/", "ja", "fr", "fr"},
+		{"empty query falls through", "/?lang=", "", "ru", "ru"},
+		{"nothing supported", "/?lang=ja", "ko", "zh", "en"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.url, nil)
+		if tt.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: "lang", Value: tt.cookie})
+		}
+		if tt.header != "" {
+			r.Header.Set("Accept-Language", tt.header)
+		}
+		if got := serve(t, chain, r); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestMiddlewareDefaultResolver(t *testing.T) {
+	p := negotiator(t, "en", "en", "ru").Lenient(nil)
+	chain := func(next http.Handler) http.Handler { return p.Middleware(next) }
+	r := httptest.NewRequest("GET", "/?lang=en", nil)
+	r.Header.Set("Accept-Language", "ru-RU, en;q=0.5")
+	if got := serve(t, chain, r); got != "ru" {
+		t.Errorf("expected the Accept-Language header to decide, got %q", got)
+	}
+}
+
+func TestMiddlewarePathPrefix(t *testing.T) {
+	p := negotiator(t, "en", "en", "pt_BR").Lenient(nil)
+	chain := func(next http.Handler) http.Handler { return p.Middleware(next, ByPathPrefix()) }
+	for path, want := range map[string]string{"/pt-br/about": "pt_BR", "/pt_BR": "pt_BR", "/about": "en", "/": "en"} {
+		if got := serve(t, chain, httptest.NewRequest("GET", path, nil)); got != want {
+			t.Errorf("%s: expected %q, got %q", path, want, got)
+		}
+	}
+}
+
+func TestMiddlewareContext(t *testing.T) {
+	dir := newProject(t, map[string]string{".po": "msgid \"Hello\"\nmsgstr \"Bonjour\"\n"})
+	p, err := LoadCfgE(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := p.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tr, _ := FromContext(r.Context())
+		w.Write([]byte(tr.G("Hello")))
+	}), ByQuery("lang"))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/?lang=fr-FR", nil))
+	if got := rec.Body.String(); got != "Bonjour" {
+		t.Errorf("expected the handler to translate with the fr catalog, got %q", got)
+	}
+
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("expected no translator in a bare context")
+	}
+	tr := Translator{Locale: "fr"}
+	if got, ok := FromContext(NewContext(context.Background(), tr)); !ok || got.Locale != "fr" {
+		t.Errorf("expected the translator stored by NewContext, got %v, %v", got.Locale, ok)
+	}
+}
@@ -0,0 +1,128 @@
+package translate
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// langRange is a language range from an Accept-Language header
+type langRange struct {
+	tag string // normalized, e.g. "en_US"
+	q   float64
+}
+
+// NewFromAcceptLanguage creates the best available translator for the
+// value of an HTTP Accept-Language header (e.g. "pt-BR,pt;q=0.8,en;q=0.5").
+// Ranges are tried in order of quality value, first by RFC 4647 lookup
+// ("en-US-x-foo" matches "en_US" or "en") and then by filtering ("en"
+// matches "en_GB"), falling back on the default locale if nothing matches.
+func (p POGOCtrl) NewFromAcceptLanguage(header string) Translator {
+	if p.o.General.ProjectFN == "" {
+		panic("no pogo configuration loaded")
+	}
+	return p.newTranslator(p.negotiate(parseAcceptLanguage(header)))
+}
+
+// NewFromRequest creates the best available translator for the
+// Accept-Language header of an HTTP request.
+func (p POGOCtrl) NewFromRequest(r *http.Request) Translator {
+	return p.NewFromAcceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// negotiate picks the supported locale that best satisfies the ranges,
+// or the default locale if none does
+func (p POGOCtrl) negotiate(ranges []langRange) string {
+	if locale, ok := p.match(ranges); ok {
+		return locale
+	}
+	return p.def
+}
+
+// match picks the supported locale that best satisfies the ranges
+func (p POGOCtrl) match(ranges []langRange) (string, bool) {
+	targets := make(map[string]string) // normalized -> configured
+	for _, v := range p.o.General.Targets {
+		targets[strings.ToLower(normalizeLocale(v))] = v
+	}
+	for _, r := range ranges {
+		if r.tag == "*" {
+			return p.def, true
+		}
+
+		// lookup: truncate the range until it matches a target
+		tag := strings.ToLower(r.tag)
+		for {
+			if v, ok := targets[tag]; ok && p.Supported(v) {
+				return v, true
+			}
+			i := strings.LastIndex(tag, "_")
+			if i < 0 {
+				break
+			}
+			tag = tag[:i]
+			if j := strings.LastIndex(tag, "_"); j >= 0 && len(tag)-j == 2 {
+				tag = tag[:j] // never leave a singleton subtag (e.g. "x") at the end
+			}
+		}
+
+		// filtering: the first target the range is a prefix of
+		prefix := strings.ToLower(r.tag) + "_"
+		for _, v := range p.o.General.Targets {
+			if strings.HasPrefix(strings.ToLower(normalizeLocale(v)), prefix) && p.Supported(v) {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseAcceptLanguage parses an Accept-Language header into its language
+// ranges, sorted by descending quality value. Ranges with a quality value
+// of 0 (i.e. "not acceptable") are dropped.
+func parseAcceptLanguage(header string) (res []langRange) {
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") && !strings.HasPrefix(param, "Q=") {
+				continue
+			}
+			if v, err := strconv.ParseFloat(param[2:], 64); err == nil && v >= 0 && v <= 1 {
+				q = v
+			}
+		}
+		if q == 0 {
+			continue
+		}
+		res = append(res, langRange{normalizeLocale(tag), q})
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].q > res[j].q })
+	return
+}
+
+// normalizeLocale converts a BCP 47 language tag into the form used for
+// pogo targets: subtags joined by "_", the language in lower case, regions
+// in upper case and scripts in title case (e.g. "zh-hant-tw" -> "zh_Hant_TW").
+func normalizeLocale(tag string) string {
+	subtags := strings.FieldsFunc(tag, func(r rune) bool { return r == '-' || r == '_' })
+	for k, v := range subtags {
+		switch {
+		case k == 0:
+			subtags[k] = strings.ToLower(v)
+		case len(v) == 2:
+			subtags[k] = strings.ToUpper(v)
+		case len(v) == 4:
+			subtags[k] = strings.ToUpper(v[:1]) + strings.ToLower(v[1:])
+		default:
+			subtags[k] = strings.ToLower(v)
+		}
+	}
+	return strings.Join(subtags, "_")
+}
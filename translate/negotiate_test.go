@@ -0,0 +1,87 @@
+package translate
+
+import (
+	"fmt"
+	spec "github.com/Sam-Izdat/pogo/gtspec"
+	"strings"
+	"testing"
+)
+
+// negotiator returns a controller supporting targets, with def as the
+// default locale
+func negotiator(t *testing.T, def string, targets ...string) POGOCtrl {
+	var o spec.Config
+	o.General.ProjectFN = "test"
+	o.General.DefaultLocale = def
+	o.General.Targets = targets
+	return newCtrl(o, nil)
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		name, header string
+		want         string
+	}{
+		{"empty", "", ""},
+		{"single", "en", "en;1"},
+		{"qualities", "pt-BR,pt;q=0.8,en;q=0.5", "pt_BR;1 pt;0.8 en;0.5"},
+		{"sorted by quality", "en;q=0.5, fr, de;q=0.7", "fr;1 de;0.7 en;0.5"},
+		{"equal qualities keep order", "da;q=0.5, en-gb;q=0.5, en;q=0.5", "da;0.5 en_GB;0.5 en;0.5"},
+		{"upper case Q", "fr;Q=0.3, it", "it;1 fr;0.3"},
+		{"other parameters", "fr;level=1;q=0.4", "fr;0.4"},
+		{"q=0 excluded", "de;q=0, en", "en;1"},
+		{"q=0.0 excluded", "de;q=0.0, en;q=0.1", "en;0.1"},
+		{"wildcard", "*;q=0.1, es", "es;1 *;0.1"},
+		{"normalized", "zh-hant-tw, SR_latn", "zh_Hant_TW;1 sr_Latn;1"},
+		{"malformed q", "en;q=abc, fr;q=2, it;q=-1", "en;1 fr;1 it;1"},
+		{"empty ranges", ", ;q=0.5,, de", "de;1"},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, r := range parseAcceptLanguage(tt.header) {
+			got = append(got, fmt.Sprintf("%s;%g", r.tag, r.q))
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("%s: parseAcceptLanguage(%q) = %q, want %q", tt.name, tt.header, strings.Join(got, " "), tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	p := negotiator(t, "en", "en", "pt_BR", "zh_Hant_TW", "de_AT", "fr")
+	tests := []struct {
+		name, header string
+		want         string
+		ok           bool
+	}{
+		{"exact", "pt-BR", "pt_BR", true},
+		{"case", "PT-br", "pt_BR", true},
+		{"region to base", "en-US", "en", true},
+		{"private use to base", "en-US-x-foo", "en", true},
+		{"base to region", "pt", "pt_BR", true},
+		{"base to script and region", "zh", "zh_Hant_TW", true},
+		{"script to region", "zh-Hant", "zh_Hant_TW", true},
+		{"sibling region", "de-DE", "", false},
+		{"by quality", "fr;q=0.5, pt-BR;q=0.9", "pt_BR", true},
+		{"unsupported skipped", "ja, fr;q=0.2", "fr", true},
+		{"q=0 excluded", "pt-BR;q=0, fr;q=0.1", "fr", true},
+		{"q=0 only", "pt-BR;q=0", "", false},
+		{"wildcard", "ja, *;q=0.5", "en", true},
+		{"wildcard after match", "fr;q=0.9, *;q=0.5", "fr", true},
+		{"malformed", "!!, ;q=1, xx-", "", false},
+		{"empty", "", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := p.match(parseAcceptLanguage(tt.header))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: match(%q) = %q, %v, want %q, %v", tt.name, tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNegotiateDefault(t *testing.T) {
+	p := negotiator(t, "fr", "en", "fr")
+	if got := p.negotiate(parseAcceptLanguage("ja, ko;q=0.5")); got != "fr" {
+		t.Errorf("expected the default locale, got %q", got)
+	}
+}
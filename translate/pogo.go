@@ -2,99 +2,407 @@ package translate
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"github.com/Sam-Izdat/pogo/catalog"
 	gt "github.com/Sam-Izdat/pogo/deps/gettext"
 	spec "github.com/Sam-Izdat/pogo/gtspec"
+	"io/fs"
 	"io/ioutil"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 // Translator delivers translation methods for a particular locale.
 // It is exported for reference, but the New constructor should be
 // used to initialize every translator.
 type Translator struct {
-	Locale string
-	Ctrl   POGOCtrl
+	Locale   string
+	Fallback []string // locales consulted, in order, for missing entries
+	Ctrl     POGOCtrl
 }
 
-type collection map[string]gt.Catalog
-
-// POGOCtrl is a configured handler for constructing translators
+// POGOCtrl is a configured handler for constructing translators.
+// It is safe for concurrent use.
 type POGOCtrl struct {
-	o        spec.Config
-	Catalogs collection
+	o         spec.Config
+	fsys      fs.FS // catalogs are read from here, or from disk if nil
+	cache     *catalogCache
+	lenient   bool
+	report    func(error)
+	def       string          // see DefaultLocale
+	supported map[string]bool // the targets; never written after loading
 }
 
+// LangDefault is the locale assumed when an unsupported one is requested:
+// the default_locale of the configuration or, failing that, "UNSUPPORTED",
+// which simply yields the untranslated strings.
+//
+// Deprecated: LangDefault and LangsSupported only mirror the configuration
+// loaded last, and reading them while a configuration loads is a data race.
+// Controllers keep their own; use POGOCtrl.DefaultLocale and
+// POGOCtrl.Supported.
 var LangDefault string
 var LangsSupported = map[string]bool{}
 
-// LoadCfg takes the path of the project directory
-// (relative to $GOPATH/src/) containing the POGO.toml
-// configuration file and loads the configuration variables.
-// Normally, this will be the main directory of your package.
+// mirrorMu serializes the updates of the deprecated globals
+var mirrorMu sync.Mutex
+
+// LoadCfg takes the path of the project directory containing the
+// POGO.toml configuration file and loads the configuration variables.
+// Normally, this will be the main directory of your package, given by
+// its import path; it is looked for within the enclosing module, under
+// $GOPATH/src/ and next to the executable, unless $POGO_PROJECT says
+// otherwise. An absolute path is taken as is.
+// It panics if the configuration cannot be loaded; see LoadCfgE.
 func LoadCfg(path string) POGOCtrl {
-	var err error
-	o, err := spec.LoadOptionsGOPATH(path)
+	p, err := LoadCfgE(path)
 	if err != nil {
 		panic(err)
 	}
-	LangDefault = "UNSUPPORTED"
+	return p
+}
+
+// LoadCfgE is like LoadCfg but returns an *Error of kind ErrMissingConfig
+// or ErrInvalidConfig instead of panicking.
+func LoadCfgE(path string) (POGOCtrl, error) {
+	o, err := spec.LoadOptionsFind(path)
+	if err != nil {
+		kind := ErrInvalidConfig
+		if errors.Is(err, os.ErrNotExist) {
+			kind = ErrMissingConfig
+		}
+		return POGOCtrl{}, &Error{Kind: kind, Path: path, Err: err}
+	}
+	return newCtrl(o, nil), nil
+}
+
+// LoadFS loads the configuration from the POGO.toml file at the root of
+// fsys and reads the catalogs from fsys too, so that they can be embedded
+// in the binary:
+//
+//	//go:embed POGO.toml locale
+//	var files embed.FS
+//	var POGO = translate.LoadFS(files)
+//
+// dir_locale must lie within fsys, as "%PROJECT%/locale" does. It panics
+// if the configuration cannot be loaded; see LoadFSE.
+func LoadFS(fsys fs.FS) POGOCtrl {
+	p, err := LoadFSE(fsys)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// LoadFSE is like LoadFS but returns an *Error of kind ErrMissingConfig
+// or ErrInvalidConfig instead of panicking.
+func LoadFSE(fsys fs.FS) (POGOCtrl, error) {
+	o, err := spec.LoadOptionsFS(fsys)
+	if err != nil {
+		kind := ErrInvalidConfig
+		if errors.Is(err, fs.ErrNotExist) {
+			kind = ErrMissingConfig
+		}
+		return POGOCtrl{}, &Error{Kind: kind, Path: spec.CFGFN, Err: err}
+	}
+	return newCtrl(o, fsys), nil
+}
+
+func newCtrl(o spec.Config, fsys fs.FS) POGOCtrl {
+	p := POGOCtrl{o: o, fsys: fsys, cache: newCatalogCache(), def: "UNSUPPORTED",
+		supported: make(map[string]bool)}
+	if o.General.DefaultLocale != "" {
+		p.def = o.General.DefaultLocale
+	}
 	for _, v := range o.General.Targets {
+		p.supported[v] = true
+	}
+
+	mirrorMu.Lock()
+	LangDefault = p.def
+	for v := range p.supported {
 		LangsSupported[v] = true
 	}
-	return POGOCtrl{o, make(collection)}
+	mirrorMu.Unlock()
+	return p
+}
+
+// DefaultLocale returns the locale assumed when an unsupported one is
+// requested: the default_locale of the configuration or, failing that,
+// "UNSUPPORTED", which simply yields the untranslated strings.
+func (p POGOCtrl) DefaultLocale() string {
+	return p.def
+}
+
+// Supported reports whether a locale is one of the configured targets
+func (p POGOCtrl) Supported(locale string) bool {
+	return p.supported[locale]
+}
+
+// Lenient returns a copy of the controller that never panics over a
+// catalog that is missing or corrupt. Its translators degrade to the
+// fallback locales or the untranslated strings instead, and the problem
+// is passed to report, if not nil. A failed catalog is not retried until
+// it is reloaded.
+func (p POGOCtrl) Lenient(report func(error)) POGOCtrl {
+	p.lenient, p.report = true, report
+	return p
 }
 
-// New takes a locale string and creates a new translator
+// New takes a locale string and creates a new translator. A regional
+// locale that is not supported itself (e.g. "pt_PT") is served by its base
+// language ("pt") if that is; anything else gets the default locale.
+// Unless the controller is lenient, New panics if a catalog cannot be
+// loaded; see NewE.
 func (p POGOCtrl) New(locale string) Translator {
 	if p.o.General.ProjectFN == "" {
 		panic("no pogo configuration loaded")
 	}
-	if supported, ok := LangsSupported[locale]; !ok || !supported {
-		locale = LangDefault
-	} else {
-		p.readMo(locale)
+	return p.newTranslator(p.resolve(locale))
+}
+
+// NewE is like New but returns an *Error of kind ErrMissingCatalog or
+// ErrCorruptCatalog instead of panicking. The translator returned along
+// with an error is still usable; it just lacks the catalog in question.
+func (p POGOCtrl) NewE(locale string) (Translator, error) {
+	if p.o.General.ProjectFN == "" {
+		return Translator{}, &Error{Kind: ErrMissingConfig, Err: errors.New("no pogo configuration loaded")}
 	}
-	return Translator{locale, p}
+	return p.newTranslatorE(p.resolve(locale))
+}
+
+// resolve maps a requested locale onto a supported one
+func (p POGOCtrl) resolve(locale string) string {
+	if p.Supported(locale) {
+		return locale
+	}
+	if base := baseLang(locale); p.Supported(base) {
+		return base
+	}
+	return p.def
 }
 
 // NewQV takes a slice of locale strings, sorted by quality value and creates
 // the best available translator, falling back on default (first) language if
-// no match is found.
+// no match is found. Locales are matched the same way as by
+// NewFromAcceptLanguage, so "en-us" will find "en_US".
 func (p POGOCtrl) NewQV(locales []string) Translator {
 	if p.o.General.ProjectFN == "" {
 		panic("no pogo configuration loaded")
 	}
-	var locale string
+	return p.newTranslator(p.negotiate(qvRanges(locales)))
+}
+
+// NewQVE is like NewQV but returns an error instead of panicking, as NewE.
+func (p POGOCtrl) NewQVE(locales []string) (Translator, error) {
+	if p.o.General.ProjectFN == "" {
+		return Translator{}, &Error{Kind: ErrMissingConfig, Err: errors.New("no pogo configuration loaded")}
+	}
+	return p.newTranslatorE(p.negotiate(qvRanges(locales)))
+}
+
+func qvRanges(locales []string) (res []langRange) {
 	for _, v := range locales {
-		if supported, ok := LangsSupported[v]; ok && supported {
-			locale = v
-			break
+		res = append(res, langRange{normalizeLocale(v), 1})
+	}
+	return
+}
+
+// newTranslator creates a translator for a locale, panicking or reporting
+// any catalog that failed to load depending on the controller's mode
+func (p POGOCtrl) newTranslator(locale string) Translator {
+	t, err := p.newTranslatorE(locale)
+	if err != nil {
+		if !p.lenient {
+			panic(err)
+		}
+		if p.report != nil {
+			p.report(err)
 		}
 	}
-	if locale == "" {
-		locale = LangDefault
+	return t
+}
+
+// newTranslatorE creates a translator for a locale, along with its
+// fallback chain, and loads the catalogs involved. The first error
+// encountered is returned, but all catalogs are attempted.
+func (p POGOCtrl) newTranslatorE(locale string) (t Translator, err error) {
+	t = Translator{Locale: locale, Fallback: p.fallback(locale), Ctrl: p}
+	for _, v := range t.chain() {
+		if !p.Supported(v) {
+			continue
+		}
+		if e := p.readCatalog(v); e != nil && err == nil {
+			err = e
+		}
 	}
-	p.readMo(locale)
-	return Translator{locale, p}
+	return
 }
 
-func (p *POGOCtrl) readMo(locale string) {
-	if _, ok := p.Catalogs[locale]; ok {
-		return
+// fallback lists the supported locales that fill in for missing entries
+// of a locale: its base language, then the default locale
+// (e.g. "pt_BR" -> "pt" -> "en"). The msgid itself is the last resort.
+func (p POGOCtrl) fallback(locale string) (res []string) {
+	for _, v := range []string{baseLang(locale), p.def} {
+		if v == locale || !p.Supported(v) {
+			continue
+		}
+		dup := false
+		for _, w := range res {
+			dup = dup || w == v
+		}
+		if !dup {
+			res = append(res, v)
+		}
+	}
+	return
+}
+
+func baseLang(locale string) string {
+	return strings.Split(locale, "_")[0]
+}
+
+// chain lists the translator's locale followed by its fallbacks
+func (t Translator) chain() []string {
+	return append([]string{t.Locale}, t.Fallback...)
+}
+
+// singular returns the translation of key from the first catalog in the
+// fallback chain that has one, or nil
+func (t Translator) singular(key string) []byte {
+	for _, locale := range t.chain() {
+		c := t.Ctrl.Catalog(locale)
+		if c == nil {
+			continue
+		}
+		if msg, ok := c.Msgs[key]; ok && msg.Str != nil {
+			return msg.Str
+		}
+	}
+	return nil
+}
+
+// plural returns the plural form of key for quantity ct from the first
+// catalog in the fallback chain that has one, or nil
+func (t Translator) plural(key string, ct spec.Operands) []byte {
+	for _, locale := range t.chain() {
+		c := t.Ctrl.Catalog(locale)
+		if c == nil {
+			continue
+		}
+		msg, ok := c.Msgs[key]
+		if !ok {
+			continue
+		}
+		idx, err := spec.GetPluralIdxOperands(c.Header.Get("Plural-Forms"), locale, ct)
+		if err == nil && idx < len(msg.StrPlural) && msg.StrPlural[idx] != nil {
+			return msg.StrPlural[idx]
+		}
+	}
+	return nil
+}
+
+// ordinal returns the ordinal form of the message id with context ctxt for
+// number ct from the first catalog in the fallback chain that has one, or
+// nil
+func (t Translator) ordinal(ctxt, id string, ct spec.Operands) []byte {
+	for _, locale := range t.chain() {
+		c := t.Ctrl.Catalog(locale)
+		if c == nil {
+			continue
+		}
+		cat, _ := spec.GetOrdinalCategory(locale, ct)
+		if msg, ok := c.Msgs[spec.OrdinalContext(cat, ctxt)+"\x04"+id]; ok && msg.Str != nil {
+			return msg.Str
+		}
 	}
-	fn := strings.Join([]string{p.o.General.ProjectFN, ".", locale, ".mo"}, "")
-	path := filepath.Join(p.o.General.DirLocale, locale, p.o.General.DirMessages, fn)
-	data, err := ioutil.ReadFile(path)
+	return nil
+}
+
+// Catalog returns the catalog loaded for a locale, or nil if there is none.
+// Catalogs are loaded as translators needing them are created.
+func (p POGOCtrl) Catalog(locale string) *gt.Catalog {
+	return p.cache.get(locale)
+}
+
+// readCatalog loads the catalog for a locale, unless it is already loaded.
+// The compiled .mo file is used if there is one, otherwise (or if the
+// configuration says to prefer it) the .po file is read directly.
+func (p POGOCtrl) readCatalog(locale string) error {
+	return p.cache.load(locale, p.reader(locale))
+}
+
+// reader returns the function that reads the catalog for a locale. It
+// notes the stamp of the files before reading them, so that Watch can tell
+// when they change afterwards.
+func (p POGOCtrl) reader(locale string) func() (*gt.Catalog, error) {
+	return func() (*gt.Catalog, error) {
+		p.cache.stamps.Store(locale, p.stamp(locale))
+		path := p.catalogPath(locale)
+		if _, err := p.stat(path + ".po"); err == nil {
+			if _, err := p.stat(path + ".mo"); err != nil || p.o.General.PreferPo {
+				return p.readPo(locale, path+".po")
+			}
+		}
+		return p.readMo(locale, path+".mo")
+	}
+}
+
+// catalogPath returns the path of the catalog files for a locale, sans
+// extension
+func (p POGOCtrl) catalogPath(locale string) string {
+	fn := strings.Join([]string{p.o.General.ProjectFN, ".", locale}, "")
+	if p.fsys != nil {
+		return path.Join(p.o.General.DirLocale, locale, p.o.General.DirMessages, fn)
+	}
+	return filepath.Join(p.o.General.DirLocale, locale, p.o.General.DirMessages, fn)
+}
+
+func (p POGOCtrl) stat(name string) (fs.FileInfo, error) {
+	if p.fsys != nil {
+		return fs.Stat(p.fsys, name)
+	}
+	return os.Stat(name)
+}
+
+func (p POGOCtrl) readFile(name string) ([]byte, error) {
+	if p.fsys != nil {
+		return fs.ReadFile(p.fsys, name)
+	}
+	return ioutil.ReadFile(name)
+}
+
+func (p POGOCtrl) readMo(locale, path string) (*gt.Catalog, error) {
+	data, err := p.readFile(path)
 	if err != nil {
-		panic(err)
+		return nil, &Error{ErrMissingCatalog, locale, path, err}
 	}
 	c := gt.NewCatalog()
 	if err := c.ReadMo(bytes.NewReader(data)); err != nil {
-		panic(err)
+		return nil, &Error{ErrCorruptCatalog, locale, path, err}
+	}
+	return c, nil
+}
+
+func (p POGOCtrl) readPo(locale, path string) (*gt.Catalog, error) {
+	data, err := p.readFile(path)
+	if err != nil {
+		return nil, &Error{ErrMissingCatalog, locale, path, err}
+	}
+	msgs, err := catalog.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, &Error{ErrCorruptCatalog, locale, path, err}
 	}
-	p.Catalogs[locale] = *c
+	iter, _ := catalog.NewIterator(msgs, false)
+	c := gt.NewCatalog()
+	if err := c.Read(iter); err != nil {
+		return nil, &Error{ErrCorruptCatalog, locale, path, err}
+	}
+	return c, nil
 }
 
 // G translates a string. The first argument must be
@@ -114,14 +422,11 @@ func (t Translator) G(input ...interface{}) string {
 		}
 	}
 
-	c := t.Ctrl.Catalogs[t.Locale]
-	if msg, ok := c.Msgs[id]; ok {
-		if text := msg.Str; text != nil {
-			if len(input) < 2 {
-				return string(text)
-			}
-			return fmt.Sprintf(string(text), input[1:]...)
+	if text := t.singular(id); text != nil {
+		if len(input) < 2 {
+			return string(text)
 		}
+		return fmt.Sprintf(string(text), input[1:]...)
 	}
 
 	if len(input) == 1 {
@@ -138,12 +443,13 @@ func (t Translator) G(input ...interface{}) string {
 // the second must be the plural form; the *last* argument
 // must be the quantity; any other arguments preceding it
 // will be translated, if possible, and considered arguments
-// for sprintf.
+// for sprintf. The quantity may be any integer or float,
+// or a formatted decimal string such as "1.50".
 func (t Translator) NG(input ...interface{}) string {
 	if len(input) < 3 {
 		return ""
 	}
-	ct := input[len(input)-1].(int)
+	ct := quantity(input[len(input)-1])
 
 	// translate chain of remaining string inputs
 	for k, v := range input[2:] {
@@ -152,20 +458,11 @@ func (t Translator) NG(input ...interface{}) string {
 		}
 	}
 
-	idx, err := spec.GetPluralIdx(t.Locale, ct)
-	if err == nil {
-		c := t.Ctrl.Catalogs[t.Locale]
-		if msg, ok := c.Msgs[input[0].(string)]; ok {
-			if text := msg.StrPlural[idx]; text != nil {
-				if len(input) < 3 {
-					return fmt.Sprintf(string(text), ct)
-				}
-				return fmt.Sprintf(string(text), input[2:]...)
-			}
-		}
+	if text := t.plural(input[0].(string), ct); text != nil {
+		return fmt.Sprintf(string(text), input[2:]...)
 	}
 
-	if ct == 1 {
+	if isOne(ct) {
 		return fmt.Sprintf(input[0].(string), input[2:]...)
 	} else {
 		return fmt.Sprintf(input[1].(string), input[2:]...)
@@ -188,15 +485,12 @@ func (t Translator) PG(input ...interface{}) string {
 		}
 	}
 
-	c := t.Ctrl.Catalogs[t.Locale]
 	key := strings.Join([]string{input[0].(string), "\x04", input[1].(string)}, "")
-	if msg, ok := c.Msgs[key]; ok {
-		if text := msg.Str; text != nil {
-			if len(input) < 3 {
-				return string(text)
-			}
-			return fmt.Sprintf(string(text), input[2:]...)
+	if text := t.singular(key); text != nil {
+		if len(input) < 3 {
+			return string(text)
 		}
+		return fmt.Sprintf(string(text), input[2:]...)
 	}
 	return fmt.Sprintf(input[1].(string), input[2:]...)
 }
@@ -208,11 +502,12 @@ func (t Translator) PG(input ...interface{}) string {
 // of the string to be translated; the third must be the plural form;
 // the *last* argument must be the quantity; any other arguments
 // preceding it will be translated, if possible, and considered
-// arguments for sprintf.
+// arguments for sprintf. The quantity may be any number, as with NG.
 func (t Translator) NPG(input ...interface{}) string {
 	if len(input) < 4 {
 		return ""
 	}
+	ct := quantity(input[len(input)-1])
 
 	// translate chain of remaining string inputs
 	for k, v := range input[3:] {
@@ -221,24 +516,76 @@ func (t Translator) NPG(input ...interface{}) string {
 		}
 	}
 
-	ct := input[len(input)-1].(int)
-	idx, err := spec.GetPluralIdx(t.Locale, ct)
-	if err == nil {
-		c := t.Ctrl.Catalogs[t.Locale]
-		key := strings.Join([]string{input[0].(string), "\x04", input[1].(string)}, "")
-		if msg, ok := c.Msgs[key]; ok {
-			if text := msg.StrPlural[idx]; text != nil {
-				if len(input) < 5 {
-					return fmt.Sprintf(string(text), ct)
-				}
-				return fmt.Sprintf(string(text), input[3:]...)
-			}
-		}
+	key := strings.Join([]string{input[0].(string), "\x04", input[1].(string)}, "")
+	if text := t.plural(key, ct); text != nil {
+		return fmt.Sprintf(string(text), input[3:]...)
 	}
 
-	if ct == 1 {
+	if isOne(ct) {
 		return fmt.Sprintf(input[1].(string), input[3:]...)
 	} else {
 		return fmt.Sprintf(input[2].(string), input[3:]...)
 	}
 }
+
+// OG translates a string containing an ordinal number, according to
+// a language's ordinal rules (e.g. "1st", "2nd", "3rd" and "4th" in
+// English). The first argument must be the string to be translated; the
+// *last* argument must be the number; any other arguments preceding it
+// will be translated, if possible, and considered arguments for sprintf,
+// along with the number. Catalogs hold a form of the string for each of
+// the ordinal categories of their language (see gtspec.OrdinalContext).
+func (t Translator) OG(input ...interface{}) string {
+	if len(input) < 2 {
+		return ""
+	}
+	ct := quantity(input[len(input)-1])
+
+	// translate chain of remaining string inputs
+	for k, v := range input[1:] {
+		if s, ok := v.(string); ok {
+			input[k+1] = t.G(s)
+		}
+	}
+
+	if text := t.ordinal("", input[0].(string), ct); text != nil {
+		return fmt.Sprintf(string(text), input[1:]...)
+	}
+	return fmt.Sprintf(input[0].(string), input[1:]...)
+}
+
+// POG translates a string containing an ordinal number with context. The
+// first argument must be the context, the second must be the string to be
+// translated; the rest are as for OG.
+func (t Translator) POG(input ...interface{}) string {
+	if len(input) < 3 {
+		return ""
+	}
+	ct := quantity(input[len(input)-1])
+
+	// translate chain of remaining string inputs
+	for k, v := range input[2:] {
+		if s, ok := v.(string); ok {
+			input[k+2] = t.G(s)
+		}
+	}
+
+	if text := t.ordinal(input[0].(string), input[1].(string), ct); text != nil {
+		return fmt.Sprintf(string(text), input[2:]...)
+	}
+	return fmt.Sprintf(input[1].(string), input[2:]...)
+}
+
+// quantity takes the plural operands from the last argument of NG or NPG
+func quantity(ct interface{}) spec.Operands {
+	op, err := spec.OperandsOf(ct)
+	if err != nil {
+		panic("pogo: bad quantity: " + err.Error())
+	}
+	return op
+}
+
+// isOne tells whether an untranslated string takes the singular form
+func isOne(ct spec.Operands) bool {
+	return ct.IsInt() && ct.I == 1
+}
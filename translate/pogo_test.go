@@ -0,0 +1,409 @@
+package translate
+
+import (
+	"errors"
+	gt "github.com/Sam-Izdat/pogo/deps/gettext"
+	spec "github.com/Sam-Izdat/pogo/gtspec"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// Run with -race: these tests are meant to shake out data races in
+// catalog loading and lookups.
+
+const goroutines, rounds = 32, 100
+
+func loadExample(t *testing.T) POGOCtrl {
+	path, err := filepath.Abs(filepath.Join("..", "example"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return LoadCfg(path)
+}
+
+func hammer(fn func(i int)) {
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				fn(g*rounds + i)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentNew(t *testing.T) {
+	p := loadExample(t)
+	var failed int32
+	hammer(func(i int) {
+		var tr Translator
+		if i%2 == 0 {
+			tr = p.New("ru")
+		} else {
+			tr = p.NewQV([]string{"de", "ru"})
+		}
+		if tr.Locale != "ru" {
+			atomic.StoreInt32(&failed, 1)
+		}
+	})
+	if failed != 0 {
+		t.Error("expected every translator to get locale ru")
+	}
+	if p.Catalog("ru") == nil {
+		t.Error("expected ru catalog to be loaded")
+	}
+}
+
+func TestControllersIndependent(t *testing.T) {
+	a := negotiator(t, "en", "en", "fr").Lenient(nil)
+	b := negotiator(t, "de", "de", "ru").Lenient(nil)
+	tests := []struct {
+		p      POGOCtrl
+		locale string
+		want   string
+	}{
+		{a, "ru", "en"},
+		{a, "fr_CA", "fr"},
+		{b, "ru", "ru"},
+		{b, "fr", "de"},
+	}
+	for _, tt := range tests {
+		if got := tt.p.New(tt.locale).Locale; got != tt.want {
+			t.Errorf("%s with default %s: expected %q, got %q", tt.locale, tt.p.DefaultLocale(), tt.want, got)
+		}
+	}
+	if got := a.New("fr").Fallback; len(got) != 1 || got[0] != "en" {
+		t.Errorf("expected fr to fall back on en alone, got %q", got)
+	}
+	if got, ok := a.match(parseAcceptLanguage("ru, *;q=0.1")); got != "en" || !ok {
+		t.Errorf("expected the wildcard to get en, got %q, %v", got, ok)
+	}
+
+	// loading another configuration meanwhile changes nothing for a
+	var failed int32
+	hammer(func(i int) {
+		if i%10 == 0 {
+			var o spec.Config
+			o.General.ProjectFN, o.General.DefaultLocale = "other", "ru"
+			o.General.Targets = []string{"ru"}
+			newCtrl(o, nil)
+			return
+		}
+		if tr := a.New("ru"); tr.Locale != "en" {
+			atomic.StoreInt32(&failed, 1)
+		}
+	})
+	if failed != 0 {
+		t.Error("expected loading another configuration not to affect the controller")
+	}
+}
+
+func TestConcurrentLookups(t *testing.T) {
+	p := loadExample(t)
+	cases := []struct {
+		fn   func(tr Translator) string
+		want string
+	}{
+		{func(tr Translator) string { return tr.G("Internationalization Example") }, "Пример Интернационализации"},
+		{func(tr Translator) string { return tr.PG("color", "orange") }, "оранжевый"},
+		{func(tr Translator) string { return tr.NG("%d chair", "%d chairs", 3) }, "3 стула"},
+		{func(tr Translator) string { return tr.NPG("fruit", "I have %d orange!", "I have %d oranges!", 5) }, "У меня 5 апельсинов"},
+	}
+	var mu sync.Mutex
+	errs := map[string]string{}
+	hammer(func(i int) {
+		c := cases[i%len(cases)]
+		if got := c.fn(p.New("ru")); got != c.want {
+			mu.Lock()
+			errs[c.want] = got
+			mu.Unlock()
+		}
+	})
+	for want, got := range errs {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestCacheLoadsOnce(t *testing.T) {
+	c := newCatalogCache()
+	var loads int32
+	hammer(func(i int) {
+		c.load("xx", func() (*gt.Catalog, error) {
+			atomic.AddInt32(&loads, 1)
+			time.Sleep(time.Millisecond)
+			return gt.NewCatalog(), nil
+		})
+		if c.get("xx") == nil {
+			t.Error("expected catalog after load")
+		}
+	})
+	if loads != 1 {
+		t.Errorf("expected catalog to be read once, read %d times", loads)
+	}
+}
+
+func TestCacheRetriesAfterPanic(t *testing.T) {
+	c := newCatalogCache()
+	func() {
+		defer func() { recover() }()
+		c.load("xx", func() (*gt.Catalog, error) { panic("corrupt") })
+	}()
+	if c.get("xx") != nil {
+		t.Fatal("expected no catalog after failed load")
+	}
+	c.load("xx", func() (*gt.Catalog, error) { return gt.NewCatalog(), nil })
+	if c.get("xx") == nil {
+		t.Error("expected catalog after second load")
+	}
+}
+
+func TestCacheRemembersFailure(t *testing.T) {
+	c := newCatalogCache()
+	var loads int32
+	fail := errors.New("corrupt")
+	hammer(func(i int) {
+		err := c.load("xx", func() (*gt.Catalog, error) {
+			atomic.AddInt32(&loads, 1)
+			return nil, fail
+		})
+		if err != fail {
+			t.Errorf("expected %v, got %v", fail, err)
+		}
+	})
+	if loads != 1 {
+		t.Errorf("expected catalog to be read once, read %d times", loads)
+	}
+}
+
+// newProject sets up a project with a configuration for the locale "fr"
+// and whatever catalog files are given, keyed by extension
+func newProject(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	cfg := "[general]\ntargets = [\"fr\"]\nproject_filename = \"test\"\n" +
+		"dir_locale = \"%PROJECT%/locale\"\ndir_messages = \"LC_MESSAGES\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "POGO.toml"), []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	msgs := filepath.Join(dir, "locale", "fr", "LC_MESSAGES")
+	if err := os.MkdirAll(msgs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for ext, data := range files {
+		if err := os.WriteFile(filepath.Join(msgs, "test.fr"+ext), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadCfgEMissing(t *testing.T) {
+	t.Setenv(spec.EnvProject, "")
+	dir := t.TempDir()
+	_, err := LoadCfgE(dir)
+	if !errors.Is(err, ErrMissingConfig) {
+		t.Errorf("expected ErrMissingConfig, got %v", err)
+	}
+	var nf *spec.NotFoundError
+	if !errors.As(err, &nf) || len(nf.Tried) != 1 || nf.Tried[0] != dir {
+		t.Errorf("expected %s to be the only location tried, got %v", dir, err)
+	}
+}
+
+func TestLoadCfgEModule(t *testing.T) {
+	t.Setenv(spec.EnvProject, "")
+	root := newProject(t, nil)
+	sub := filepath.Join(root, "cmd", "app")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/proj // app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(sub)
+	p, err := LoadCfgE("example.com/proj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.o.General.DirProject != root {
+		t.Errorf("expected project at %s, got %s", root, p.o.General.DirProject)
+	}
+	_, err = LoadCfgE("example.com/proj/cmd/app")
+	var nf *spec.NotFoundError
+	if !errors.As(err, &nf) || nf.Tried[0] != sub {
+		t.Errorf("expected %s to be tried first, got %v", sub, err)
+	}
+}
+
+func TestLoadCfgEEnv(t *testing.T) {
+	dir := newProject(t, nil)
+	t.Setenv(spec.EnvProject, dir)
+	p, err := LoadCfgE("example.com/elsewhere")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.o.General.DirProject != dir {
+		t.Errorf("expected project at %s, got %s", dir, p.o.General.DirProject)
+	}
+
+	empty := t.TempDir()
+	t.Setenv(spec.EnvProject, empty)
+	_, err = LoadCfgE(dir)
+	var nf *spec.NotFoundError
+	if !errors.As(err, &nf) || len(nf.Tried) != 1 || nf.Tried[0] != empty {
+		t.Errorf("expected only %s to be tried, got %v", empty, err)
+	}
+}
+
+func TestNewEErrors(t *testing.T) {
+	cases := []struct {
+		files map[string]string
+		kind  error
+	}{
+		{nil, ErrMissingCatalog},
+		{map[string]string{".mo": "not a catalog"}, ErrCorruptCatalog},
+		{map[string]string{".po": "msgid \"x\"\nmsgstr"}, ErrCorruptCatalog},
+		{map[string]string{".po": "msgid \"x\"\nmsgstr \"y\"\n"}, nil},
+	}
+	for _, c := range cases {
+		p, err := LoadCfgE(newProject(t, c.files))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr, err := p.NewE("fr")
+		if !errors.Is(err, c.kind) || (c.kind == nil) != (err == nil) {
+			t.Errorf("%v: expected %v, got %v", c.files, c.kind, err)
+		}
+		var perr *Error
+		if err != nil && (!errors.As(err, &perr) || perr.Locale != "fr") {
+			t.Errorf("expected *Error for locale fr, got %#v", err)
+		}
+		if tr.Locale != "fr" {
+			t.Errorf("expected usable translator, got %#v", tr)
+		}
+	}
+}
+
+func TestLenient(t *testing.T) {
+	p, err := LoadCfgE(newProject(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reported []error
+	p = p.Lenient(func(err error) { reported = append(reported, err) })
+	tr := p.New("fr")
+	if got := tr.G("Hello %s", "world"); got != "Hello world" {
+		t.Errorf("expected source string, got %q", got)
+	}
+	if len(reported) != 1 || !errors.Is(reported[0], ErrMissingCatalog) {
+		t.Errorf("expected one ErrMissingCatalog report, got %v", reported)
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	if _, err := LoadFSE(fstest.MapFS{}); !errors.Is(err, ErrMissingConfig) {
+		t.Errorf("expected ErrMissingConfig, got %v", err)
+	}
+	fsys := fstest.MapFS{
+		"POGO.toml": {Data: []byte("[general]\ntargets = [\"fr\"]\nproject_filename = \"test\"\n" +
+			"dir_locale = \"%PROJECT%/locale\"\ndir_messages = \"LC_MESSAGES\"\n")},
+		"locale/fr/LC_MESSAGES/test.fr.po": {Data: []byte("msgid \"x\"\nmsgstr \"y\"\n")},
+	}
+	p, err := LoadFSE(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := p.NewE("fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tr.G("x"); got != "y" {
+		t.Errorf("expected y, got %q", got)
+	}
+}
+
+func TestCatalogPluralForms(t *testing.T) {
+	dir := newProject(t, map[string]string{".po": "msgid \"\"\nmsgstr \"Plural-Forms: nplurals=2; plural=n>5;\\n\"\n\n" +
+		"msgid \"%d cat\"\nmsgid_plural \"%d cats\"\nmsgstr[0] \"%d few\"\nmsgstr[1] \"%d many\"\n"})
+	p, err := LoadCfgE(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := p.New("fr")
+	for ct, want := range map[int]string{1: "1 few", 5: "5 few", 6: "6 many"} {
+		if got := tr.NG("%d cat", "%d cats", ct); got != want {
+			t.Errorf("%d: expected %q, got %q", ct, want, got)
+		}
+	}
+}
+
+func TestDecimalPlurals(t *testing.T) {
+	dir := newProject(t, map[string]string{".po": "msgid \"%v hour\"\nmsgid_plural \"%v hours\"\n" +
+		"msgstr[0] \"%v heure\"\nmsgstr[1] \"%v heures\"\n\n" +
+		"msgctxt \"clock\"\nmsgid \"%v hour\"\nmsgid_plural \"%v hours\"\n" +
+		"msgstr[0] \"%v heure\"\nmsgstr[1] \"%v heures\"\n\n" +
+		"msgid \"0.75\"\nmsgstr \"12\"\n"})
+	p, err := LoadCfgE(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := p.New("fr")
+	cases := []struct {
+		ct   interface{}
+		want string
+	}{
+		{1, "1 heure"},
+		{1.5, "1.5 heure"},
+		{2.5, "2.5 heures"},
+		{"0.50", "0.50 heure"},
+		{int64(3), "3 heures"},
+	}
+	for _, c := range cases {
+		if got := tr.NG("%v hour", "%v hours", c.ct); got != c.want {
+			t.Errorf("%v: expected %q, got %q", c.ct, c.want, got)
+		}
+	}
+	// the count is taken before the string arguments are translated
+	if got := tr.NPG("clock", "%v hour", "%v hours", "0.75"); got != "12 heure" {
+		t.Errorf("expected the plural form for 0.75, got %q", got)
+	}
+	if got := tr.NG("%v day", "%v days", 1.0); got != "1 day" {
+		t.Errorf("expected untranslated singular, got %q", got)
+	}
+}
+
+func TestOrdinals(t *testing.T) {
+	dir := newProject(t, map[string]string{".po": "msgid \"%d place\"\nmsgstr \"%d places\"\n\n" +
+		"msgctxt \"ordinal:one\"\nmsgid \"%d place\"\nmsgstr \"%der\"\n\n" +
+		"msgctxt \"ordinal:other\"\nmsgid \"%d place\"\nmsgstr \"%de\"\n\n" +
+		"msgctxt \"ordinal:one|race\"\nmsgid \"Lap %d\"\nmsgstr \"%der tour\"\n\n" +
+		"msgctxt \"ordinal:other|race\"\nmsgid \"Lap %d\"\nmsgstr \"%de tour\"\n"})
+	p, err := LoadCfgE(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := p.New("fr")
+	cases := []struct {
+		got, want string
+	}{
+		{tr.OG("%d place", 1), "1er"},
+		{tr.OG("%d place", 2), "2e"},
+		{tr.OG("%d place", 11), "11e"},
+		{tr.POG("race", "Lap %d", 1), "1er tour"},
+		{tr.POG("race", "Lap %d", 3), "3e tour"},
+		{tr.OG("Heat %d", 2), "Heat 2"},
+		{tr.G("%d place", 2), "2 places"},
+	}
+	for _, c := range cases {
+		if c.got != c.want {
+			t.Errorf("expected %q, got %q", c.want, c.got)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package translate
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Reload reads every catalog loaded so far again and swaps the new
+// versions in for all translators, current and future. Catalogs that
+// failed to load are tried again. If a catalog cannot be read, the
+// version loaded before stays in use and the error is returned, along
+// with any others.
+func (p POGOCtrl) Reload() error {
+	var errs []error
+	for _, locale := range p.cache.locales() {
+		if err := p.cache.reload(locale, p.reader(locale)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Watch starts polling the files of the loaded catalogs every interval and
+// reloads those that changed since they were read, judging by their size
+// and modification time.
+// Errors are passed to the report function of a lenient controller, if
+// any. Call the returned function to stop watching.
+func (p POGOCtrl) Watch(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			for _, locale := range p.cache.locales() {
+				if last, ok := p.cache.stamps.Load(locale); ok && last.(string) == p.stamp(locale) {
+					continue
+				}
+				err := p.cache.reload(locale, p.reader(locale))
+				if err != nil && p.report != nil {
+					p.report(err)
+				}
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// stamp summarizes the size and modification time of the catalog files of
+// a locale, so that a change to any of them shows
+func (p POGOCtrl) stamp(locale string) (res string) {
+	path := p.catalogPath(locale)
+	for _, ext := range []string{".mo", ".po"} {
+		if fi, err := p.stat(path + ext); err == nil {
+			res += ext + ":" + strconv.FormatInt(fi.Size(), 10) + ":" +
+				strconv.FormatInt(fi.ModTime().UnixNano(), 10) + ";"
+		}
+	}
+	return
+}
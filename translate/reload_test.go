@@ -0,0 +1,85 @@
+package translate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFrPo(t *testing.T, dir, data string) {
+	fn := filepath.Join(dir, "locale", "fr", "LC_MESSAGES", "test.fr.po")
+	if err := os.WriteFile(fn, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := newProject(t, map[string]string{".po": "msgid \"x\"\nmsgstr \"y\"\n"})
+	p, err := LoadCfgE(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := p.New("fr")
+	if got := tr.G("x"); got != "y" {
+		t.Fatalf("expected y, got %q", got)
+	}
+
+	writeFrPo(t, dir, "msgid \"x\"\nmsgstr \"z\"\n")
+	if err := p.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := tr.G("x"); got != "z" {
+		t.Errorf("expected existing translator to see z, got %q", got)
+	}
+
+	writeFrPo(t, dir, "msgid \"x\"\nmsgstr")
+	if err := p.Reload(); !errors.Is(err, ErrCorruptCatalog) {
+		t.Errorf("expected ErrCorruptCatalog, got %v", err)
+	}
+	if got := tr.G("x"); got != "z" {
+		t.Errorf("expected previous catalog to stay in use, got %q", got)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := newProject(t, map[string]string{".po": "msgid \"x\"\nmsgstr \"y\"\n"})
+	p, err := LoadCfgE(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := p.New("fr")
+	stop := p.Watch(5 * time.Millisecond)
+	defer stop()
+
+	writeFrPo(t, dir, "msgid \"x\"\nmsgstr \"changed\"\n")
+	deadline := time.Now().Add(2 * time.Second)
+	for tr.G("x") != "changed" {
+		if time.Now().After(deadline) {
+			t.Fatal("catalog was not reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWatchChangeBeforeFirstPoll(t *testing.T) {
+	dir := newProject(t, map[string]string{".po": "msgid \"x\"\nmsgstr \"y\"\n"})
+	p, err := LoadCfgE(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := p.New("fr")
+	// a change between loading and the first poll must not be taken as
+	// the state the catalog was loaded in
+	writeFrPo(t, dir, "msgid \"x\"\nmsgstr \"changed\"\n")
+	stop := p.Watch(time.Hour)
+	defer stop()
+	deadline := time.Now().Add(2 * time.Second)
+	for tr.G("x") != "changed" {
+		if time.Now().After(deadline) {
+			t.Fatal("catalog was not reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}